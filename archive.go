@@ -0,0 +1,68 @@
+package rotw
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// archiveBaseDir 获取分割后文件所在目录树的根目录，归档时保持文件相对于该目录的路径
+//
+// 使用文件名模板时为模板中第一个占位符之前的目录，eg: log/%Y/%m/app.log 为 log
+func archiveBaseDir(cfg *RotateWriterConfig) string {
+	if len(cfg.FilenamePattern) == 0 {
+		return filepath.Dir(cfg.LogPath)
+	}
+	pattern := filepath.Clean(filepath.FromSlash(cfg.FilenamePattern))
+	idx := strings.IndexAny(pattern, "%{")
+	if idx < 0 {
+		return filepath.Dir(pattern)
+	}
+	return filepath.Dir(pattern[:idx+1])
+}
+
+// archivePath 获取文件归档后的路径
+func archivePath(cfg *RotateWriterConfig, path string) string {
+	rel, err := filepath.Rel(archiveBaseDir(cfg), path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(path)
+	}
+	return filepath.Join(cfg.ArchiveDir, rel)
+}
+
+// newArchiveNamer 创建识别归档目录中文件的命名器
+func newArchiveNamer(cfg *RotateWriterConfig, rule RotateRule) fileNamer {
+	c := *cfg
+	if len(cfg.FilenamePattern) > 0 {
+		c.FilenamePattern = archivePath(cfg, filepath.Clean(filepath.FromSlash(cfg.FilenamePattern)))
+	} else {
+		c.LogPath = archivePath(cfg, cfg.LogPath)
+	}
+	return newFileNamer(&c, rule)
+}
+
+// moveFile 将文件移动到 target，目标文件已经存在时返回错误
+//
+// 优先使用重命名，失败时（eg: 跨文件系统）复制到临时文件并落盘，重命名为 target 后再删除原文件
+func moveFile(ctx context.Context, path string, target string) error {
+	if _, err := os.Lstat(target); err == nil {
+		return errors.New("archive file already exists")
+	}
+	if err := keepDirs(filepath.Dir(target)); err != nil {
+		return err
+	}
+	if err := os.Rename(path, target); err == nil {
+		return nil
+	}
+	copyFn := func(dst io.Writer, src io.Reader) error {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+	if err := transformFile(ctx, path, target, copyFn); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,23 @@
+package rotw
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_archivePath(t *testing.T) {
+	cases := []struct {
+		cfg  *RotateWriterConfig
+		path string
+		want string
+	}{
+		{&RotateWriterConfig{LogPath: "log/test.log", ArchiveDir: "archive"}, "log/test.log.2024-01-02.gz", "archive/test.log.2024-01-02.gz"},
+		{&RotateWriterConfig{FilenamePattern: "log/%Y/%m/app.log", ArchiveDir: "/data/archive"}, "log/2024/01/app.1.log", "/data/archive/2024/01/app.1.log"},
+		{&RotateWriterConfig{FilenamePattern: "log/app-%Y%m%d.log", ArchiveDir: "archive"}, "log/app-20240102.log", "archive/app-20240102.log"},
+	}
+	for _, c := range cases {
+		if got := archivePath(c.cfg, filepath.FromSlash(c.path)); got != filepath.FromSlash(c.want) {
+			t.Errorf("archivePath(%s) = %s, want %s", c.path, got, c.want)
+		}
+	}
+}
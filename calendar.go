@@ -0,0 +1,118 @@
+package rotw
+
+import (
+	"time"
+)
+
+// 基于日历计算周期的起止时间，所有计算都在 t 所在的时区进行
+//
+// 能整除1小时的周期按照本地的分秒对齐，1小时周期在当前时间上加减，能整除1天的小时周期和天周期使用 time.Date 计算，
+// 因此在夏令时切换的当天也能对齐到本地的整点和零点；其他周期按照本地时间对 span 取模对齐
+
+const day = 24 * time.Hour
+
+// truncateMinutes 将 t 截断到本地时间 span 的整数倍，span 需能整除1小时
+func truncateMinutes(t time.Time, span time.Duration) time.Time {
+	elapsed := time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	return t.Add(-(elapsed % span))
+}
+
+// civilDays 计算 t 的本地日期距离 1970-01-01 的天数
+func civilDays(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// durationStart 计算 t 所在的 span 周期的起始时间
+func durationStart(t time.Time, span time.Duration) time.Time {
+	loc := t.Location()
+	switch {
+	case span <= time.Hour && time.Hour%span == 0:
+		return truncateMinutes(t, span)
+	case span < day && day%span == 0 && span%time.Hour == 0:
+		// 按小时向前查找，直到本地小时不再属于同一个周期，夏令时跳过或重复的小时也能正确处理
+		k := t.Hour() / int(span/time.Hour)
+		start := truncateMinutes(t, time.Hour)
+		for {
+			prev := start.Add(-time.Hour)
+			if prev.Day() != t.Day() || prev.Hour()/int(span/time.Hour) != k {
+				return start
+			}
+			start = prev
+		}
+	case span%day == 0:
+		k := int(span / day)
+		return time.Date(t.Year(), t.Month(), t.Day()-civilDays(t)%k, 0, 0, 0, 0, loc)
+	default:
+		_, offset := t.Zone()
+		// Unix时间戳没有时区信息，所以需要手动加上时区偏移
+		localTs := time.Duration(t.UnixNano()) + time.Duration(offset)*time.Second
+		return t.Add(-(localTs % span))
+	}
+}
+
+// durationNext 计算 span 周期的下一个周期的起始时间
+func durationNext(span time.Duration) func(time.Time) time.Time {
+	return func(t time.Time) time.Time {
+		start := durationStart(t, span)
+		switch {
+		case span <= time.Hour:
+			// 小时以内的周期不受夏令时影响，直接累加
+			return start.Add(span)
+		case span < day && day%span == 0 && span%time.Hour == 0:
+			// 按小时向后查找，直到本地小时进入下一个周期
+			k := start.Hour() / int(span/time.Hour)
+			next := start.Add(time.Hour)
+			for next.Day() == start.Day() && next.Hour()/int(span/time.Hour) == k {
+				next = next.Add(time.Hour)
+			}
+			return next
+		case span%day == 0:
+			k := int(span / day)
+			return time.Date(start.Year(), start.Month(), start.Day()+k, 0, 0, 0, 0, start.Location())
+		default:
+			return start.Add(span)
+		}
+	}
+}
+
+// weekStart 计算 t 所在周的周一零点
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
+
+// weekNext 计算 t 所在周的下一周的周一零点
+func weekNext(t time.Time) time.Time {
+	start := weekStart(t)
+	return time.Date(start.Year(), start.Month(), start.Day()+7, 0, 0, 0, 0, t.Location())
+}
+
+// monthStart 计算 t 所在月的1号零点
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// monthNext 计算 t 所在月的下个月1号零点
+func monthNext(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+}
+
+// yearStart 计算 t 所在年的1月1号零点
+func yearStart(t time.Time) time.Time {
+	return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+}
+
+// yearNext 计算 t 所在年的下一年1月1号零点
+func yearNext(t time.Time) time.Time {
+	return time.Date(t.Year()+1, time.January, 1, 0, 0, 0, 0, t.Location())
+}
+
+// inLocation 将时间转换到指定时区后再计算下一个周期的起始时间
+func inLocation(next func(time.Time) time.Time, loc *time.Location) func(time.Time) time.Time {
+	if next == nil {
+		return nil
+	}
+	return func(t time.Time) time.Time {
+		return next(t.In(loc))
+	}
+}
@@ -0,0 +1,106 @@
+package rotw
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("load location %s error, err=%v", name, err)
+	}
+	return loc
+}
+
+func Test_calendarNext(t *testing.T) {
+	ny := loadLocation(t, "America/New_York")
+	kolkata := loadLocation(t, "Asia/Kolkata")
+	cases := []struct {
+		name string
+		next func(time.Time) time.Time
+		now  time.Time
+		want time.Time
+	}{
+		{"5min", durationNext(5 * time.Minute), time.Date(2024, 1, 2, 15, 7, 3, 0, kolkata), time.Date(2024, 1, 2, 15, 10, 0, 0, kolkata)},
+		{"hour", durationNext(time.Hour), time.Date(2024, 1, 2, 15, 7, 3, 0, kolkata), time.Date(2024, 1, 2, 16, 0, 0, 0, kolkata)},
+		// 夏令时开始，02:00 跳到 03:00
+		{"hour dst start", durationNext(time.Hour), time.Date(2024, 3, 10, 1, 30, 0, 0, ny), time.Date(2024, 3, 10, 3, 0, 0, 0, ny)},
+		{"day dst start", durationNext(24 * time.Hour), time.Date(2024, 3, 10, 0, 30, 0, 0, ny), time.Date(2024, 3, 11, 0, 0, 0, 0, ny)},
+		{"day dst end", durationNext(24 * time.Hour), time.Date(2024, 11, 3, 12, 0, 0, 0, ny), time.Date(2024, 11, 4, 0, 0, 0, 0, ny)},
+		{"45min", durationNext(45 * time.Minute), time.Date(2024, 1, 2, 0, 50, 0, 0, time.UTC), time.Date(2024, 1, 2, 1, 30, 0, 0, time.UTC)},
+		{"90s", durationNext(90 * time.Second), time.Date(2024, 1, 2, 0, 1, 0, 0, time.UTC), time.Date(2024, 1, 2, 0, 1, 30, 0, time.UTC)},
+		{"2h", durationNext(2 * time.Hour), time.Date(2024, 1, 2, 15, 7, 3, 0, kolkata), time.Date(2024, 1, 2, 16, 0, 0, 0, kolkata)},
+		{"2h dst start", durationNext(2 * time.Hour), time.Date(2024, 3, 10, 0, 30, 0, 0, ny), time.Date(2024, 3, 10, 3, 0, 0, 0, ny)},
+		{"3d", durationNext(3 * 24 * time.Hour), time.Date(2024, 1, 2, 12, 0, 0, 0, ny), time.Date(2024, 1, 3, 0, 0, 0, 0, ny)},
+		// 夏令时结束，01:00 重复一次
+		{"2h dst end", durationNext(2 * time.Hour), time.Date(2024, 11, 3, 1, 30, 0, 0, ny).Add(time.Hour), time.Date(2024, 11, 3, 2, 0, 0, 0, ny)},
+		{"week", weekNext, time.Date(2024, 1, 7, 23, 0, 0, 0, ny), time.Date(2024, 1, 8, 0, 0, 0, 0, ny)},
+		{"week monday", weekNext, time.Date(2024, 1, 8, 0, 0, 0, 0, ny), time.Date(2024, 1, 15, 0, 0, 0, 0, ny)},
+		{"month", monthNext, time.Date(2024, 1, 31, 12, 0, 0, 0, ny), time.Date(2024, 2, 1, 0, 0, 0, 0, ny)},
+		{"year", yearNext, time.Date(2024, 12, 31, 23, 59, 59, 0, ny), time.Date(2025, 1, 1, 0, 0, 0, 0, ny)},
+	}
+	for _, c := range cases {
+		if got := c.next(c.now); !got.Equal(c.want) {
+			t.Errorf("%s: next(%v) = %v, want %v", c.name, c.now, got, c.want)
+		}
+	}
+}
+
+func Test_generator_next_dst(t *testing.T) {
+	ny := loadLocation(t, "America/New_York")
+	cases := []struct {
+		rule string
+		now  time.Time
+		want time.Duration
+	}{
+		// 夏令时开始的当天只有23小时
+		{"day", time.Date(2024, 3, 10, 0, 30, 0, 0, ny), 22*time.Hour + 30*time.Minute},
+		// 夏令时结束的当天有25小时
+		{"day", time.Date(2024, 11, 3, 0, 30, 0, 0, ny), 24*time.Hour + 30*time.Minute},
+		{"hour", time.Date(2024, 11, 3, 1, 30, 0, 0, ny), 30 * time.Minute},
+		{"month", time.Date(2024, 3, 1, 0, 0, 0, 0, ny), 31*24*time.Hour - time.Hour},
+	}
+	for _, c := range cases {
+		now := c.now
+		setNowFunc(func() time.Time { return now })
+		rig, err := newRotateInfoGenerator(&RotateWriterConfig{
+			Rule:     c.rule,
+			LogPath:  filepath.Join(t.TempDir(), "test.log"),
+			Location: ny,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := rig.(*rotateInfoGenerator).g.(*generator).next()
+		rig.Stop()
+		if !ok || got != c.want {
+			t.Errorf("%s at %v: next() = %v, want %v", c.rule, c.now, got, c.want)
+		}
+	}
+	setNowFunc(time.Now)
+}
+
+func Test_NewRotateInfoGenerator_calendar(t *testing.T) {
+	now := time.Date(2024, 1, 3, 10, 0, 0, 0, time.Local)
+	setNowFunc(func() time.Time { return now })
+	defer setNowFunc(time.Now)
+
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	cases := map[string]string{
+		"week":  ".2024-01-01",
+		"month": ".2024-01",
+		"year":  ".2024",
+	}
+	for rule, suffix := range cases {
+		rig, err := NewRotateInfoGenerator(rule, logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rig.Get().RotatePath; got != logPath+suffix {
+			t.Errorf("%s: RotatePath = %s, want %s", rule, got, logPath+suffix)
+		}
+		rig.Stop()
+	}
+}
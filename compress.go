@@ -0,0 +1,110 @@
+package rotw
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Compressor 文件压缩器，用于压缩写完的文件
+type Compressor interface {
+	// Ext 压缩后文件的扩展名，eg: .gz
+	Ext() string
+	// Compress 将 src 的数据压缩后写入 dst
+	Compress(dst io.Writer, src io.Reader) error
+}
+
+// builtinCompressExts 内置压缩器的扩展名，带有这些扩展名的文件同样视为分割后的文件
+var builtinCompressExts = []string{".gz", ".zz", ".deflate"}
+
+// checkCompressExt 检查压缩文件扩展名是否有效，必须以 . 开头且不包含路径分隔符，
+// 不能是 .1 这样的纯数字扩展名，避免与分段序号混淆
+func checkCompressExt(ext string) error {
+	if len(ext) < 2 || ext[0] != '.' || strings.ContainsAny(ext, `/\`) || seqRegexp.MatchString(ext) {
+		return errors.New("invalid compress ext")
+	}
+	return nil
+}
+
+// splitCompressExt 拆分文件路径中的压缩扩展名，没有压缩扩展名时返回空字符串
+//
+// custom 为写入器配置的压缩器的扩展名，为空时只识别内置压缩器的扩展名
+func splitCompressExt(path string, custom string) (string, string) {
+	if len(custom) > 0 && len(path) > len(custom) && strings.HasSuffix(path, custom) {
+		return strings.TrimSuffix(path, custom), custom
+	}
+	ext := filepath.Ext(path)
+	if !slices.Contains(builtinCompressExts, ext) {
+		return path, ""
+	}
+	return strings.TrimSuffix(path, ext), ext
+}
+
+// NewGzipCompressor 创建 gzip 压缩器，扩展名为 .gz，level 取值范围同 compress/gzip
+func NewGzipCompressor(level int) Compressor {
+	return &stdCompressor{
+		ext: ".gz",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+	}
+}
+
+// NewZlibCompressor 创建 zlib 压缩器，扩展名为 .zz，level 取值范围同 compress/zlib
+func NewZlibCompressor(level int) Compressor {
+	return &stdCompressor{
+		ext: ".zz",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zlib.NewWriterLevel(w, level)
+		},
+	}
+}
+
+// NewFlateCompressor 创建 flate 压缩器，扩展名为 .deflate，level 取值范围同 compress/flate
+func NewFlateCompressor(level int) Compressor {
+	return &stdCompressor{
+		ext: ".deflate",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, level)
+		},
+	}
+}
+
+// stdCompressor 基于标准库压缩算法的压缩器
+type stdCompressor struct {
+	ext       string
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+func (c *stdCompressor) Ext() string {
+	return c.ext
+}
+
+func (c *stdCompressor) Compress(dst io.Writer, src io.Reader) error {
+	zw, err := c.newWriter(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(zw, src); err != nil {
+		_ = zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// compressFile 将文件压缩为 path+c.Ext()，压缩文件落盘后才删除原文件
+//
+// 中途失败或者 ctx 被取消时保留原文件
+func compressFile(ctx context.Context, path string, c Compressor) error {
+	if err := transformFile(ctx, path, path+c.Ext(), c.Compress); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
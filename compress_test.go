@@ -0,0 +1,117 @@
+package rotw
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_compressFile(t *testing.T) {
+	cases := []struct {
+		c      Compressor
+		reader func(r io.Reader) (io.Reader, error)
+	}{
+		{NewGzipCompressor(gzip.BestSpeed), func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{NewZlibCompressor(zlib.DefaultCompression), func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }},
+		{NewFlateCompressor(flate.BestCompression), func(r io.Reader) (io.Reader, error) { return flate.NewReader(r), nil }},
+	}
+	content := bytes.Repeat([]byte("hello world\n"), 100)
+	for _, c := range cases {
+		path := filepath.Join(t.TempDir(), "test.log.2024-01-02")
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := compressFile(context.Background(), path, c.c); err != nil {
+			t.Fatalf("%s: %v", c.c.Ext(), err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s: original file should be removed", c.c.Ext())
+		}
+		data, err := os.ReadFile(path + c.c.Ext())
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := c.reader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := io.ReadAll(r); !bytes.Equal(got, content) {
+			t.Errorf("%s: unexpected content after decompress", c.c.Ext())
+		}
+		if name, ext := splitCompressExt(path+c.c.Ext(), ""); name != path || ext != c.c.Ext() {
+			t.Errorf("%s: splitCompressExt() = %s, %s", c.c.Ext(), name, ext)
+		}
+	}
+	// 取消后保留原文件，不留下临时文件
+	path := filepath.Join(t.TempDir(), "test.log.2024-01-02")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := compressFile(ctx, path, NewGzipCompressor(gzip.DefaultCompression)); err == nil {
+		t.Error("compress should fail after cancel")
+	}
+	if matches, _ := filepath.Glob(path + "*"); len(matches) != 1 || matches[0] != path {
+		t.Errorf("unexpected files after cancel: %v", matches)
+	}
+	// 压缩文件已经存在时不覆盖，保留原文件
+	if err := os.WriteFile(path+".gz", []byte("exists"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressFile(context.Background(), path, NewGzipCompressor(gzip.DefaultCompression)); !os.IsExist(err) {
+		t.Errorf("compress should fail when target exists, err=%v", err)
+	}
+	if data, _ := os.ReadFile(path + ".gz"); string(data) != "exists" {
+		t.Errorf("existing compressed file should not be overwritten, got %q", data)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, content) {
+		t.Error("original file should be kept")
+	}
+}
+
+type testCompressor struct{}
+
+func (testCompressor) Ext() string { return ".test" }
+
+func (testCompressor) Compress(dst io.Writer, src io.Reader) error {
+	_, err := io.Copy(dst, src)
+	return err
+}
+
+func Test_Compressor(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("day"), WithCompressor(testCompressor{}))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	defer func() {
+		_ = rw.Close()
+	}()
+	nf, ok := rw.(*rotateWriter).namer.Match(logPath + ".2024-01-02.1.test")
+	if !ok || nf.Ext != ".test" || nf.Seq != 1 || nf.Period != ".2024-01-02" {
+		t.Errorf("configured ext should match, got %+v, %v", nf, ok)
+	}
+	// 其他写入器不识别该扩展名
+	other, err := NewRotateWriterWithOpt(filepath.Join(t.TempDir(), "test.log"), WithRule("day"))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	defer func() {
+		_ = other.Close()
+	}()
+	if nf, ok = other.(*rotateWriter).namer.Match(logPath + ".2024-01-02.1.test"); ok && nf.Ext != "" {
+		t.Errorf("ext of other writer should not match, got %+v", nf)
+	}
+	for _, ext := range []string{"", ".", ".1", "gz", ".a/b"} {
+		if err = checkCompressExt(ext); err == nil {
+			t.Errorf("checkCompressExt(%q) should fail", ext)
+		}
+	}
+}
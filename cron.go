@@ -0,0 +1,236 @@
+package rotw
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronLayout cron 规则的文件名格式，精确到分钟
+const cronLayout = "2006-01-02_1504"
+
+// cronSchedule 标准5段式 cron 表达式: 分 时 日 月 周
+//
+// 每一段使用位图记录允许的取值
+type cronSchedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// 日和周是否为 *，两者都不是 * 时满足其一即可，否则需要同时满足
+	domStar bool
+	dowStar bool
+}
+
+type cronField struct {
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	cronMinute = cronField{min: 0, max: 59}
+	cronHour   = cronField{min: 0, max: 23}
+	cronDom    = cronField{min: 1, max: 31}
+	cronMonth  = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 周日可以写作 0 或 7
+	cronDow = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// cronSearchYears 查找触发时间的最大年数，超过则认为表达式永远不会触发
+const cronSearchYears = 5
+
+// parseCron 解析标准5段式 cron 表达式，eg: "0 */6 * * *", "30 2 * * *"
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.New("cron expression must have 5 fields")
+	}
+	var (
+		c   cronSchedule
+		err error
+	)
+	if c.minute, err = cronMinute.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if c.hour, err = cronHour.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if c.dom, err = cronDom.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if c.month, err = cronMonth.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if c.dow, err = cronDow.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	// 7 和 0 都表示周日
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = fields[2] == "*"
+	c.dowStar = fields[4] == "*"
+	return &c, nil
+}
+
+// parse 解析 cron 表达式的一段，支持 *、a、a-b、*/n、a-b/n、a/n 以及逗号分隔的列表
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, errors.New("invalid cron step: " + part)
+			}
+			step = n
+		}
+		var start, end int
+		switch {
+		case rangePart == "*":
+			start, end = f.min, f.max
+		case strings.Contains(rangePart, "-"):
+			lo, hi, _ := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = f.value(lo); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(hi); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if start, err = f.value(rangePart); err != nil {
+				return 0, err
+			}
+			end = start
+			// a/n 表示从 a 开始到最大值，每隔 n 触发
+			if hasStep {
+				end = f.max
+			}
+		}
+		if start > end {
+			return 0, errors.New("invalid cron range: " + part)
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+// value 解析单个取值，支持数字和名称
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.New("invalid cron value: " + s)
+	}
+	if v < f.min || v > f.max {
+		return 0, errors.New("cron value out of range: " + s)
+	}
+	return v, nil
+}
+
+// dayMatch 判断日期是否满足日和周的限制
+func (c *cronSchedule) dayMatch(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// next 计算 t 之后的下一次触发时间，找不到时返回零值
+func (c *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	// 从下一分钟开始查找
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	yearLimit := t.Year() + cronSearchYears
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+	for c.month&(1<<uint(t.Month())) == 0 {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+	for !c.dayMatch(t) {
+		month := t.Month()
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Month() != month {
+			goto WRAP
+		}
+	}
+	for c.hour&(1<<uint(t.Hour())) == 0 {
+		day := t.Day()
+		t = t.Add(time.Hour - time.Duration(t.Minute())*time.Minute)
+		if t.Day() != day {
+			goto WRAP
+		}
+	}
+	for c.minute&(1<<uint(t.Minute())) == 0 {
+		hour := t.Hour()
+		t = t.Add(time.Minute)
+		if t.Hour() != hour {
+			goto WRAP
+		}
+	}
+	return t
+}
+
+// prev 计算 t 之前（包含 t 所在的分钟）的最近一次触发时间，即 t 所在周期的起始时间，找不到时返回零值
+func (c *cronSchedule) prev(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(-time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	yearLimit := t.Year() - cronSearchYears
+WRAP:
+	if t.Year() < yearLimit {
+		return time.Time{}
+	}
+	for c.month&(1<<uint(t.Month())) == 0 {
+		// 跳到上个月的最后一分钟
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc).Add(-time.Minute)
+		if t.Month() == time.December {
+			goto WRAP
+		}
+	}
+	for !c.dayMatch(t) {
+		month := t.Month()
+		// 跳到前一天的最后一分钟
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc).Add(-time.Minute)
+		if t.Month() != month {
+			goto WRAP
+		}
+	}
+	for c.hour&(1<<uint(t.Hour())) == 0 {
+		day := t.Day()
+		// 跳到上一小时的最后一分钟
+		t = t.Add(-time.Duration(t.Minute()+1) * time.Minute)
+		if t.Day() != day {
+			goto WRAP
+		}
+	}
+	for c.minute&(1<<uint(t.Minute())) == 0 {
+		hour := t.Hour()
+		t = t.Add(-time.Minute)
+		if t.Hour() != hour {
+			goto WRAP
+		}
+	}
+	return t
+}
@@ -0,0 +1,100 @@
+package rotw
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_parseCron(t *testing.T) {
+	valid := []string{"* * * * *", "0 */6 * * *", "30 2 * * *", "0 0 1,15 * mon-fri", "5/15 0-12/3 * jan-jun 7"}
+	for _, expr := range valid {
+		if _, err := parseCron(expr); err != nil {
+			t.Errorf("parseCron(%q) error: %v", expr, err)
+		}
+	}
+	invalid := []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"}
+	for _, expr := range invalid {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) should fail", expr)
+		}
+	}
+}
+
+func Test_cronSchedule_next_prev(t *testing.T) {
+	loc := time.UTC
+	cases := []struct {
+		expr string
+		now  time.Time
+		prev time.Time
+		next time.Time
+	}{
+		{
+			expr: "0 */6 * * *",
+			now:  time.Date(2024, 1, 2, 7, 13, 5, 0, loc),
+			prev: time.Date(2024, 1, 2, 6, 0, 0, 0, loc),
+			next: time.Date(2024, 1, 2, 12, 0, 0, 0, loc),
+		},
+		{
+			expr: "30 2 * * *",
+			now:  time.Date(2024, 1, 2, 1, 0, 0, 0, loc),
+			prev: time.Date(2024, 1, 1, 2, 30, 0, 0, loc),
+			next: time.Date(2024, 1, 2, 2, 30, 0, 0, loc),
+		},
+		{
+			expr: "30 2 * * *",
+			now:  time.Date(2024, 1, 2, 2, 30, 0, 0, loc),
+			prev: time.Date(2024, 1, 2, 2, 30, 0, 0, loc),
+			next: time.Date(2024, 1, 3, 2, 30, 0, 0, loc),
+		},
+		{
+			expr: "0 0 1 * *",
+			now:  time.Date(2024, 12, 31, 23, 59, 59, 0, loc),
+			prev: time.Date(2024, 12, 1, 0, 0, 0, 0, loc),
+			next: time.Date(2025, 1, 1, 0, 0, 0, 0, loc),
+		},
+		{
+			expr: "0 9 * * mon",
+			now:  time.Date(2024, 1, 3, 0, 0, 0, 0, loc),
+			prev: time.Date(2024, 1, 1, 9, 0, 0, 0, loc),
+			next: time.Date(2024, 1, 8, 9, 0, 0, 0, loc),
+		},
+		{
+			expr: "0 0 29 2 *",
+			now:  time.Date(2025, 1, 1, 0, 0, 0, 0, loc),
+			prev: time.Date(2024, 2, 29, 0, 0, 0, 0, loc),
+			next: time.Date(2028, 2, 29, 0, 0, 0, 0, loc),
+		},
+	}
+	for _, c := range cases {
+		sched, err := parseCron(c.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sched.prev(c.now); !got.Equal(c.prev) {
+			t.Errorf("%q prev(%v) = %v, want %v", c.expr, c.now, got, c.prev)
+		}
+		if got := sched.next(c.now); !got.Equal(c.next) {
+			t.Errorf("%q next(%v) = %v, want %v", c.expr, c.now, got, c.next)
+		}
+	}
+}
+
+func Test_NewRotateInfoGenerator_cron(t *testing.T) {
+	now := time.Date(2024, 1, 2, 7, 13, 5, 0, time.Local)
+	setNowFunc(func() time.Time { return now })
+	defer setNowFunc(time.Now)
+
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	rig, err := NewRotateInfoGenerator("0 */6 * * *", logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rig.Stop()
+	if got, want := rig.Get().RotatePath, logPath+".2024-01-02_0600"; got != want {
+		t.Errorf("RotatePath = %s, want %s", got, want)
+	}
+	if _, err := NewRotateInfoGenerator("0 0 30 2 *", logPath); err != ErrInvalidRule {
+		t.Errorf("never firing cron should be invalid, err=%v", err)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build linux || darwin
+
+package rotw
+
+import (
+	"os"
+	"syscall"
+)
+
+// diskUsage 获取目录所在文件系统的可用空间和总空间，单位字节
+func diskUsage(dir string) (free uint64, total uint64, err error) {
+	var stat syscall.Statfs_t
+	if err = syscall.Statfs(dir, &stat); err != nil {
+		return 0, 0, err
+	}
+	bsize := uint64(stat.Bsize)
+	return stat.Bavail * bsize, stat.Blocks * bsize, nil
+}
+
+// sameDevice 判断两个路径是否位于同一个文件系统
+func sameDevice(a string, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return infoA.Sys().(*syscall.Stat_t).Dev == infoB.Sys().(*syscall.Stat_t).Dev
+}
@@ -18,29 +18,54 @@ type Generator interface {
 	AddCallback(func(any))
 	// AddCallbackWithCtx 添加回调函数，当生成数据时，会调用回调函数
 	AddCallbackWithCtx(func(context.Context, any))
+	// Trigger 立即生成一次数据，并通知所有回调函数
+	Trigger() any
 	// Stop 停止生产数据，并 cancel 掉上下文，使用者可以自行处理上下文
 	Stop()
 }
 
 // NewGenerator 创建 Generator，并启动定时器
+//
+// 按照 span 对齐本地时间生成数据，span 为0时只在创建时生成一次数据
 func NewGenerator(span time.Duration, genFn func() any) Generator {
+	var nextFn func(time.Time) time.Time
+	if span > 0 {
+		nextFn = spanNext(span)
+	}
+	return NewGeneratorWithNext(nextFn, genFn)
+}
+
+// NewGeneratorWithNext 创建 Generator，并启动定时器
+//
+// nextFn 根据当前时间计算下一次生成数据的时间点，返回零值时不再生成数据，nextFn 为空时只在创建时生成一次数据
+func NewGeneratorWithNext(nextFn func(time.Time) time.Time, genFn func() any) Generator {
 	ctx, cancel := context.WithCancel(context.Background())
 	p := &generator{
 		ctx:    ctx,
 		cancel: cancel,
-		span:   span,
+		nextFn: nextFn,
 		genFn:  genFn,
 	}
 	p.start()
 	return p
 }
 
+// spanNext 按照固定的时间间隔，对齐 now 所在时区计算下一次触发的时间点
+func spanNext(span time.Duration) func(time.Time) time.Time {
+	return func(now time.Time) time.Time {
+		_, offset := now.Zone()
+		// Unix时间戳没有时区信息，所以需要手动加上时区偏移
+		localTs := time.Duration(now.Unix()+int64(offset)) * time.Second
+		return now.Add(span - localTs%span)
+	}
+}
+
 // generator 按照一定的时间间隔生成数据，并将生成的数据传递给注册的回调函数
 type generator struct {
 	// 控制生命周期
 	ctx         context.Context
 	cancel      func()
-	span        time.Duration
+	nextFn      func(time.Time) time.Time
 	callbacks   []func(context.Context, any)
 	genFn       func() any
 	timer       *time.Timer
@@ -71,18 +96,31 @@ func (g *generator) Get() any {
 	return g.lastProduct
 }
 
+// Trigger 立即生成一次数据，并通知所有回调函数
+func (g *generator) Trigger() any {
+	val := g.gen()
+	g.notify(val)
+	return val
+}
+
 func (g *generator) start() {
 	// 启动就生成一次数据
 	_ = g.gen()
-	// 周期为0，则不启动定时器
-	if g.span.Nanoseconds() == 0 {
+	// 没有下一次触发时间，则不启动定时器
+	if g.nextFn == nil {
+		return
+	}
+	d, ok := g.next()
+	if !ok {
 		return
 	}
 	// 启动定时器，定时生成数据
-	g.timer = time.AfterFunc(g.next(), func() {
+	g.timer = time.AfterFunc(d, func() {
 		val := g.gen()
 		g.notify(val)
-		g.timer.Reset(g.next())
+		if d, ok := g.next(); ok {
+			g.timer.Reset(d)
+		}
 	})
 	g.lastTrigger = nowFunc().Unix()
 	go g.doCheck()
@@ -126,7 +164,9 @@ func (g *generator) check() {
 	g.mux.Lock()
 	defer g.mux.Unlock()
 	g.timer.Stop()
-	g.timer.Reset(g.next())
+	if d, ok := g.next(); ok {
+		g.timer.Reset(d)
+	}
 }
 
 // gen 生成数据
@@ -144,11 +184,12 @@ func (g *generator) notify(val any) {
 	}
 }
 
-// next 计算下一次触发的时间
-func (g *generator) next() time.Duration {
-	_, offset := nowFunc().Zone()
-	// Unix时间戳没有时区信息，所以需要手动加上时区偏移
-	localTs := time.Duration(nowFunc().Unix()+int64(offset)) * time.Second
-	ret := g.span - localTs%g.span
-	return ret
+// next 计算距离下一次触发的时间，没有下一次触发时间时返回 false
+func (g *generator) next() (time.Duration, bool) {
+	now := nowFunc()
+	t := g.nextFn(now)
+	if t.IsZero() {
+		return 0, false
+	}
+	return t.Sub(now), true
 }
@@ -0,0 +1,130 @@
+package rotw
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DiskEvent 磁盘可用空间低于阈值时的事件
+type DiskEvent struct {
+	// 检查的目录
+	Dir string
+	// 处理后的可用空间，单位字节
+	Free uint64
+	// 文件系统的总空间，单位字节
+	Total uint64
+	// 为了释放空间删除的文件
+	Removed []string
+	// 是否已经停止写入，可用空间恢复后会再次触发事件并恢复写入
+	Paused bool
+}
+
+// hasDiskGuard 是否配置了磁盘可用空间检查
+func (rw *RotateWriterConfig) hasDiskGuard() bool {
+	return rw.MinFreeBytes > 0 || rw.MinFreePercent > 0
+}
+
+// isLowDisk 判断可用空间是否低于阈值
+func (rw *RotateWriterConfig) isLowDisk(free uint64, total uint64) bool {
+	if rw.MinFreeBytes > 0 && free < rw.MinFreeBytes {
+		return true
+	}
+	return rw.MinFreePercent > 0 && float64(free)*100 < rw.MinFreePercent*float64(total)
+}
+
+// doGuard 分割文件时以及定期检查磁盘可用空间
+func (r *rotateWriter) doGuard(span time.Duration) {
+	ticker := time.NewTicker(span)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.closed:
+			return
+		case <-r.guardCh:
+		case <-ticker.C:
+		}
+		r.guardDisk(r.ctx)
+	}
+}
+
+// guardDisk 可用空间低于阈值时从最早的文件开始删除，直到可用空间恢复；
+// 仍然不足且配置了 StopOnLowDisk 时停止写入
+//
+// 只在 doGuard 协程中执行，不等待按节奏进行的压缩和清理，避免磁盘空间不足时无法及时释放空间
+func (r *rotateWriter) guardDisk(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+	active := r.strategy.Path(r.rig.Get())
+	dir := filepath.Dir(active)
+	free, total, err := diskUsage(dir)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "get disk usage of %s error, err=%v\n", dir, err)
+		return
+	}
+	low := r.cfg.isLowDisk(free, total)
+	r.mux.Lock()
+	paused := r.paused
+	r.mux.Unlock()
+	if !low && !paused {
+		return
+	}
+	event := DiskEvent{Dir: dir, Free: free, Total: total}
+	if low {
+		for _, f := range r.guardFiles(dir, active) {
+			if ctx.Err() != nil {
+				return
+			}
+			if errRemove := os.Remove(f.Path); errRemove != nil {
+				// 文件可能已经被清理协程删除
+				if !os.IsNotExist(errRemove) {
+					_, _ = fmt.Fprintf(os.Stderr, "remove file %s error, err=%v\n", f.Path, errRemove)
+				}
+				continue
+			}
+			event.Removed = append(event.Removed, f.Path)
+			if free, total, err = diskUsage(dir); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "get disk usage of %s error, err=%v\n", dir, err)
+				break
+			}
+			event.Free, event.Total = free, total
+			if low = r.cfg.isLowDisk(free, total); !low {
+				break
+			}
+		}
+	}
+	event.Paused = low && r.cfg.StopOnLowDisk
+	r.mux.Lock()
+	r.paused = event.Paused
+	r.mux.Unlock()
+	if r.cfg.OnLowDisk != nil {
+		r.cfg.OnLowDisk(event)
+	}
+}
+
+// guardFiles 获取可以为了释放空间删除的文件，按从旧到新的顺序排列，不包括正在写入以及被其他写入器锁定的文件
+//
+// 归档目录与日志目录位于同一个文件系统时，归档的文件同样可以删除
+func (r *rotateWriter) guardFiles(dir string, active string) []ManagedFile {
+	files, err := getManagedFiles(r.namer, active)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "get managed files error, err=%v\n", err)
+	}
+	if r.archive != nil && sameDevice(r.cfg.ArchiveDir, dir) {
+		archived, errArchive := getManagedFiles(r.archive, "")
+		if errArchive != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "get archived files error, err=%v\n", errArchive)
+		}
+		files = mergeManagedFiles(archived, files)
+	}
+	ret := make([]ManagedFile, 0, len(files))
+	for _, f := range files {
+		if !f.Active && !r.isBusy(f.Path) {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
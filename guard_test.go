@@ -0,0 +1,30 @@
+package rotw
+
+import (
+	"testing"
+)
+
+func Test_isLowDisk(t *testing.T) {
+	cases := []struct {
+		cfg   RotateWriterConfig
+		free  uint64
+		total uint64
+		want  bool
+	}{
+		{RotateWriterConfig{}, 0, 100, false},
+		{RotateWriterConfig{MinFreeBytes: 10}, 9, 100, true},
+		{RotateWriterConfig{MinFreeBytes: 10}, 10, 100, false},
+		{RotateWriterConfig{MinFreePercent: 5}, 4, 100, true},
+		{RotateWriterConfig{MinFreePercent: 5}, 5, 100, false},
+		// 任一条件不满足即视为空间不足
+		{RotateWriterConfig{MinFreeBytes: 10, MinFreePercent: 50}, 20, 100, true},
+	}
+	for i, c := range cases {
+		if got := c.cfg.isLowDisk(c.free, c.total); got != c.want {
+			t.Errorf("case %d: isLowDisk(%d, %d) = %v, want %v", i, c.free, c.total, got, c.want)
+		}
+	}
+	if free, total, err := diskUsage(t.TempDir()); err != nil || total == 0 || free > total {
+		t.Errorf("diskUsage() = %d, %d, %v", free, total, err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin
+
+package rotw
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile 以非阻塞方式获取文件的排他建议锁，文件已被其他进程锁定时返回 errFileTaken
+func lockFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errFileTaken
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package rotw
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+)
+
+var procLockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+
+// lockFile 以非阻塞方式获取文件的排他锁，文件已被其他进程锁定时返回 errFileTaken
+//
+// 锁定文件末尾之外的一个字节，不影响其他进程读取文件内容
+func lockFile(file *os.File) error {
+	ol := &syscall.Overlapped{Offset: 0xFFFFFFFE, OffsetHigh: 0x7FFFFFFF}
+	r1, _, err := procLockFileEx.Call(
+		file.Fd(),
+		uintptr(lockfileExclusiveLock|lockfileFailImmediately),
+		0,
+		1,
+		0,
+		uintptr(unsafe.Pointer(ol)),
+	)
+	if r1 != 0 {
+		return nil
+	}
+	if errors.Is(err, errorLockViolation) {
+		return errFileTaken
+	}
+	return err
+}
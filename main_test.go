@@ -1,7 +1,17 @@
 package rotw
 
 import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 )
@@ -71,3 +81,595 @@ func Test_optional(t *testing.T) {
 		t.Fatalf(">> %v\n", err)
 	}
 }
+
+func Test_maxSize(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("hour"), WithMaxSize(100), WithCheckSpan(time.Second))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	line := []byte(strings.Repeat("a", 29) + "\n")
+	for i := 0; i < 10; i++ {
+		_, _ = rw.Write(line)
+	}
+	err = rw.Close()
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	matches, _ := filepath.Glob(logPath + ".*")
+	if len(matches) != 4 {
+		t.Fatalf("expect 4 segments, got %v", matches)
+	}
+	for _, name := range matches {
+		info, errStat := os.Stat(name)
+		if errStat != nil {
+			t.Fatal(errStat)
+		}
+		if info.Size() > 100 {
+			t.Errorf("segment %s exceeds max size, size=%d", name, info.Size())
+		}
+	}
+}
+
+func Test_maxSize_noRule(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string][]Option{
+		"log path": {},
+		"pattern":  {WithFilenamePattern(filepath.Join(dir, "pattern", "app.log"))},
+	}
+	for name, opts := range cases {
+		logPath := filepath.Join(dir, name, "app.log")
+		opts = append(opts, WithMaxSize(10), WithKeepFiles(2), WithCleanInterval(time.Millisecond), WithCleanBatch(10))
+		rw, err := NewRotateWriterWithOpt(logPath, opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for i := 0; i < 6; i++ {
+			_, _ = rw.Write([]byte("hello world\n"))
+		}
+		current := rw.(*rotateWriter).current
+		// 第0个分段即 app.log，同样按照保留策略清理
+		var matches []string
+		for i := 0; i < 150; i++ {
+			if matches, _ = filepath.Glob(filepath.Join(dir, name, "app*")); len(matches) == 2 {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err = rw.Close(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(matches) != 2 || current.Seq != 5 || !slices.Contains(matches, current.RotatePath) {
+			t.Errorf("%s: unexpected files %v, current %+v", name, matches, current)
+		}
+	}
+}
+
+func Test_maxLines(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("hour"), WithMaxLines(3), WithMaxSize(1024))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	for i := 0; i < 10; i++ {
+		_, _ = rw.Write([]byte("hello world\n"))
+	}
+	err = rw.Close()
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	matches, _ := filepath.Glob(logPath + ".*")
+	if len(matches) != 4 {
+		t.Fatalf("expect 4 segments, got %v", matches)
+	}
+}
+
+func Test_rotate(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("day"))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	_, _ = rw.Write([]byte("before\n"))
+	if err = rw.Rotate(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	_, _ = rw.Write([]byte("after\n"))
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if err = rw.Rotate(); err == nil {
+		t.Error("rotate after close should fail")
+	}
+	matches, _ := filepath.Glob(logPath + ".*")
+	sort.Strings(matches)
+	if len(matches) != 2 {
+		t.Fatalf("expect 2 files, got %v", matches)
+	}
+	data, _ := os.ReadFile(matches[1])
+	if string(data) != "after\n" {
+		t.Errorf("unexpected content of %s: %q", matches[1], data)
+	}
+}
+
+func Test_idleTimeout(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("day"), WithIdleTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	_, _ = rw.Write([]byte("before\n"))
+	time.Sleep(400 * time.Millisecond)
+	r := rw.(*rotateWriter)
+	r.mux.Lock()
+	idle := r.file == nil && r.idle
+	r.mux.Unlock()
+	if !idle {
+		t.Fatal("file should be closed after idle timeout")
+	}
+	if _, err = rw.Write([]byte("after\n")); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	// 空闲关闭后的写入切换到新的分段文件
+	matches, _ := filepath.Glob(logPath + ".*")
+	sort.Strings(matches)
+	if len(matches) != 2 {
+		t.Fatalf("expect 2 files, got %v", matches)
+	}
+	if data, _ := os.ReadFile(matches[0]); string(data) != "before\n" {
+		t.Errorf("unexpected content of %s: %q", matches[0], data)
+	}
+	if data, _ := os.ReadFile(matches[1]); string(data) != "after\n" || matches[1] != segmentPath(matches[0], 1) {
+		t.Errorf("unexpected content of %s: %q", matches[1], data)
+	}
+
+	// 极小的空闲时长不会导致检查协程出错
+	rw, err = NewRotateWriterWithOpt(filepath.Join(t.TempDir(), "test.log"), WithRule("day"), WithIdleTimeout(time.Nanosecond))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if _, err = rw.Write([]byte("hello world\n")); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+}
+
+func Test_currentLink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlink requires privilege on windows")
+	}
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("day"), WithCurrentLink(true))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	_, _ = rw.Write([]byte("before\n"))
+	if err = rw.Rotate(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	_, _ = rw.Write([]byte("after\n"))
+	current := rw.(*rotateWriter).rig.Get().RotatePath
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	target, err := os.Readlink(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != filepath.Base(current) {
+		t.Errorf("link target = %s, want %s", target, filepath.Base(current))
+	}
+	data, _ := os.ReadFile(logPath)
+	if string(data) != "after\n" {
+		t.Errorf("unexpected content: %q", data)
+	}
+}
+
+func Test_renameStrategy(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	// 上次运行遗留的文件按照修改时间所在的周期重命名
+	old := time.Now().AddDate(0, 0, -2)
+	if err := os.WriteFile(logPath, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(logPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("day"), WithWriteStrategy(StrategyRename))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	_, _ = rw.Write([]byte("before\n"))
+	if err = rw.Rotate(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	_, _ = rw.Write([]byte("after\n"))
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	expects := map[string]string{
+		logPath:                                  "after\n",
+		logPath + "." + old.Format("2006-01-02"): "old\n",
+		logPath + "." + time.Now().Format("2006-01-02"): "before\n",
+	}
+	for path, content := range expects {
+		data, errRead := os.ReadFile(path)
+		if errRead != nil || string(data) != content {
+			t.Errorf("unexpected content of %s: %q, err=%v", path, data, errRead)
+		}
+	}
+	if _, err = NewRotateWriterWithOpt(logPath, WithWriteStrategy("unknown")); err == nil {
+		t.Error("unknown write strategy should fail")
+	}
+
+	// 分割后的文件名已被压缩后的文件占用时，使用下一个分段序号
+	logPath = filepath.Join(t.TempDir(), "test.log")
+	period := logPath + "." + time.Now().Format("2006-01-02")
+	if err = os.WriteFile(period+".gz", []byte("compressed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rw, err = NewRotateWriterWithOpt(logPath, WithRule("day"), WithWriteStrategy(StrategyRename), WithCompress(true))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	_, _ = rw.Write([]byte("before\n"))
+	if err = rw.Rotate(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if data, _ := os.ReadFile(period + ".gz"); string(data) != "compressed" {
+		t.Errorf("existing compressed file should not be overwritten, got %q", data)
+	}
+	if !isPathExists(segmentPath(period, 1)) && !isPathExists(segmentPath(period, 1)+".gz") {
+		t.Errorf("rotated file should use the next free segment")
+	}
+}
+
+func Test_exclusive(t *testing.T) {
+	cases := map[string][]Option{
+		"exclusive": {WithExclusive(true)},
+		"lock file": {WithLockFile(true)},
+	}
+	for name, opts := range cases {
+		logPath := filepath.Join(t.TempDir(), "test.log")
+		opts = append(opts, WithRule("day"))
+		rw1, err := NewRotateWriterWithOpt(logPath, opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		rw2, err := NewRotateWriterWithOpt(logPath, opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		_, _ = rw1.Write([]byte("first\n"))
+		_, _ = rw2.Write([]byte("second\n"))
+		path1 := rw1.(*rotateWriter).current.RotatePath
+		path2 := rw2.(*rotateWriter).current.RotatePath
+		_ = rw1.Close()
+		_ = rw2.Close()
+		if path1 == path2 {
+			t.Fatalf("%s: two writers share the same file %s", name, path1)
+		}
+		if data, _ := os.ReadFile(path2); string(data) != "second\n" {
+			t.Errorf("%s: unexpected content of %s: %q", name, path2, data)
+		}
+	}
+}
+
+func Test_exclusive_compress(t *testing.T) {
+	cases := map[string]Option{
+		"exclusive": WithExclusive(true),
+		"lock file": WithLockFile(true),
+	}
+	for name, opt := range cases {
+		logPath := filepath.Join(t.TempDir(), "test.log")
+		rwA, err := NewRotateWriterWithOpt(logPath, WithRule("day"), opt, WithCompress(true), WithKeepFiles(1),
+			WithCleanInterval(time.Millisecond))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		rwB, err := NewRotateWriterWithOpt(logPath, WithRule("day"), opt)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		_, _ = rwA.Write([]byte("a-before\n"))
+		_, _ = rwB.Write([]byte("b-before\n"))
+		pathA := rwA.(*rotateWriter).current.RotatePath
+		pathB := rwB.(*rotateWriter).current.RotatePath
+		if err = rwA.Rotate(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// 等待 A 压缩并清理自己写完的文件，B 正在写入的文件不能被压缩或删除
+		for i := 0; i < 150 && isPathExists(pathA); i++ {
+			time.Sleep(20 * time.Millisecond)
+		}
+		time.Sleep(100 * time.Millisecond)
+		_, _ = rwB.Write([]byte("b-after\n"))
+		_ = rwA.Close()
+		_ = rwB.Close()
+		if isPathExists(pathA) {
+			t.Errorf("%s: finished file %s of writer A should be compressed", name, pathA)
+		}
+		if data, _ := os.ReadFile(pathB); string(data) != "b-before\nb-after\n" {
+			t.Errorf("%s: unexpected content of %s: %q", name, pathB, data)
+		}
+		if isPathExists(pathB + ".gz") {
+			t.Errorf("%s: live file of writer B should not be compressed", name)
+		}
+	}
+}
+
+func Test_compress(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("day"), WithMaxSize(10), WithKeepFiles(2), WithCompress(true))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	for i := 0; i < 3; i++ {
+		_, _ = rw.Write([]byte("hello world\n"))
+	}
+	current := rw.(*rotateWriter).current.RotatePath
+	// 等待后台压缩和清理完成
+	var matches []string
+	for i := 0; i < 150; i++ {
+		matches, _ = filepath.Glob(logPath + ".*")
+		sort.Strings(matches)
+		if len(matches) == 2 && strings.HasSuffix(matches[0], ".001.gz") {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if len(matches) != 2 || matches[0] != segmentPath(strings.TrimSuffix(current, ".002"), 1)+".gz" || matches[1] != current {
+		t.Fatalf("unexpected files %v, current %s", matches, current)
+	}
+	f, err := os.Open(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(zr)
+	if string(data) != "hello world\n" {
+		t.Errorf("unexpected content of %s: %q", matches[0], data)
+	}
+}
+
+func Test_maintainMerge(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	base := runtime.NumGoroutine()
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("day"), WithMaxSize(10), WithKeepFiles(1), WithCompress(true))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	for i := 0; i < 500; i++ {
+		_, _ = rw.Write([]byte("hello world\n"))
+	}
+	// 分割期间的压缩和清理请求合并处理，不会为每次分割保留一个协程
+	n := runtime.NumGoroutine()
+	for i := 0; i < 50 && n-base > 20; i++ {
+		time.Sleep(20 * time.Millisecond)
+		n = runtime.NumGoroutine()
+	}
+	if n-base > 20 {
+		t.Errorf("too many goroutines after rotating: %d, base %d", n, base)
+	}
+	start := time.Now()
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if cost := time.Since(start); cost > time.Second {
+		t.Errorf("close should not wait for queued tasks, cost %v", cost)
+	}
+}
+
+func Test_archiveDir(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "log", "test.log")
+	archiveDir := filepath.Join(dir, "archive")
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("day"), WithMaxSize(10), WithKeepFiles(1),
+		WithCompress(true), WithArchiveDir(archiveDir))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	for i := 0; i < 3; i++ {
+		_, _ = rw.Write([]byte("hello world\n"))
+	}
+	current := rw.(*rotateWriter).current.RotatePath
+	// 等待后台压缩、归档和清理完成，归档目录只保留1个文件
+	var archived []string
+	for i := 0; i < 150; i++ {
+		archived, _ = filepath.Glob(filepath.Join(archiveDir, "test.log.*"))
+		if len(archived) == 1 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	want := filepath.Join(archiveDir, filepath.Base(strings.TrimSuffix(current, ".002"))) + ".001.gz"
+	if len(archived) != 1 || archived[0] != want {
+		t.Errorf("unexpected archived files %v", archived)
+	}
+	if matches, _ := filepath.Glob(logPath + ".*"); len(matches) != 1 || matches[0] != current {
+		t.Errorf("unexpected files %v in log dir, current %s", matches, current)
+	}
+}
+
+func Test_archiveDir_conflict(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "log", "test.log")
+	archiveDir := filepath.Join(dir, "archive")
+	// 归档目录中已有同名文件，日志目录中的文件无法归档
+	if err := keepDirs(filepath.Dir(logPath)); err != nil {
+		t.Fatal(err)
+	}
+	if err := keepDirs(archiveDir); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		name := fmt.Sprintf("test.log.2024-01-0%d", i)
+		if err := os.WriteFile(filepath.Join(archiveDir, name), []byte("archived\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if i > 2 {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, "log", name), []byte("hello world\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("day"), WithKeepFiles(2), WithArchiveDir(archiveDir),
+		WithCleanInterval(time.Millisecond), WithCleanBatch(10))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	// 无法归档的文件同样按照保留策略清理
+	var left []string
+	for i := 0; i < 150; i++ {
+		left, _ = filepath.Glob(filepath.Join(dir, "log", "test.log.2024-*"))
+		if len(left) == 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if len(left) != 0 {
+		t.Errorf("files failed to archive should be cleaned, got %v", left)
+	}
+	if archived, _ := filepath.Glob(filepath.Join(archiveDir, "test.log.*")); len(archived) != 2 {
+		t.Errorf("unexpected archived files %v", archived)
+	}
+}
+
+func Test_diskGuard(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	events := make(chan DiskEvent, 100)
+	// 可用空间永远低于阈值
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("day"), WithMaxSize(10), WithCheckSpan(50*time.Millisecond),
+		WithMinFreeBytes(math.MaxUint64), WithStopOnLowDisk(true), WithOnLowDisk(func(e DiskEvent) {
+			events <- e
+		}))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	for i := 0; i < 3; i++ {
+		_, _ = rw.Write([]byte("hello world\n"))
+	}
+	current := rw.(*rotateWriter).current.RotatePath
+	var removed []string
+	for paused := false; !paused; {
+		select {
+		case e := <-events:
+			removed = append(removed, e.Removed...)
+			paused = e.Paused
+		case <-time.After(3 * time.Second):
+			t.Fatal("wait low disk event timeout")
+		}
+	}
+	if n, errWrite := rw.Write([]byte("dropped\n")); n != 8 || errWrite != nil {
+		t.Errorf("write should be dropped silently, got %d, %v", n, errWrite)
+	}
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("unexpected removed files %v", removed)
+	}
+	if matches, _ := filepath.Glob(logPath + ".*"); len(matches) != 1 || matches[0] != current {
+		t.Errorf("unexpected files %v, current %s", matches, current)
+	}
+	if data, _ := os.ReadFile(current); string(data) != "hello world\n" {
+		t.Errorf("unexpected content of %s: %q", current, data)
+	}
+}
+
+func Test_diskGuard_cleanPace(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	for i := 1; i <= 3; i++ {
+		if err := os.WriteFile(fmt.Sprintf("%s.2024-01-0%d", logPath, i), []byte("hello world\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events := make(chan DiskEvent, 100)
+	// 按节奏清理的任务长时间等待时，磁盘空间检查不被阻塞
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("day"), WithKeepFiles(1), WithCleanInterval(time.Hour),
+		WithCheckSpan(50*time.Millisecond), WithMinFreeBytes(math.MaxUint64), WithOnLowDisk(func(e DiskEvent) {
+			events <- e
+		}))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	select {
+	case e := <-events:
+		if len(e.Removed) != 3 {
+			t.Errorf("unexpected removed files %v", e.Removed)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("disk guard is blocked by clean")
+	}
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+}
+
+func Test_cleanPace(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	for i := 1; i <= 4; i++ {
+		if err := os.WriteFile(fmt.Sprintf("%s.2024-01-0%d", logPath, i), []byte("hello world\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 启动时的清理任务在关闭时退出，不再删除文件
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("day"), WithKeepFiles(1), WithCleanInterval(time.Hour))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	start := time.Now()
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if cost := time.Since(start); cost > time.Second {
+		t.Errorf("close should not wait for clean interval, cost %v", cost)
+	}
+	if matches, _ := filepath.Glob(logPath + ".*"); len(matches) != 5 {
+		t.Errorf("no file should be removed after close, got %v", matches)
+	}
+
+	// 每批删除2个文件
+	rw, err = NewRotateWriterWithOpt(logPath, WithRule("day"), WithKeepFiles(1), WithCleanInterval(10*time.Millisecond),
+		WithCleanBatch(2), WithCleanBytesPerSec(100))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	var matches []string
+	for i := 0; i < 50; i++ {
+		if matches, _ = filepath.Glob(logPath + ".*"); len(matches) == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if len(matches) != 1 || matches[0] != rw.(*rotateWriter).current.RotatePath {
+		t.Errorf("unexpected files %v", matches)
+	}
+}
@@ -0,0 +1,370 @@
+package rotw
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileNamer 生成分割后的文件路径，并识别由写入器管理的文件
+type fileNamer interface {
+	// Name 根据周期的起始时间、规则生成的文件名后缀和分段序号生成文件路径
+	Name(start time.Time, suffix string, seq int) string
+	// Glob 匹配所有可能由写入器管理的文件的 glob 表达式
+	Glob() string
+	// Match 判断文件路径是否由写入器管理，并拆分出周期部分和分段序号
+	Match(path string) (namedFile, bool)
+}
+
+// namedFile 从文件路径中识别出的周期和分段序号
+type namedFile struct {
+	// 文件路径
+	Path string
+	// 文件名中表示周期的部分，同一周期的分段文件相同
+	Period string
+	// 分段序号
+	Seq int
+	// 从文件名中解析出的周期起始时间，无法解析时为零值
+	Time time.Time
+	// 压缩文件的扩展名，未压缩时为空
+	Ext string
+}
+
+// lessNamedFile 比较两个文件的先后顺序，先按周期，再按分段序号的数值
+func lessNamedFile(a namedFile, b namedFile) bool {
+	if a.Period != b.Period {
+		return a.Period < b.Period
+	}
+	return a.Seq < b.Seq
+}
+
+// newFileNamer 根据写入器配置创建文件命名器
+func newFileNamer(cfg *RotateWriterConfig, rule RotateRule) fileNamer {
+	var compressExt string
+	if c := cfg.compressor(); c != nil {
+		compressExt = c.Ext()
+	}
+	if len(cfg.FilenamePattern) > 0 {
+		n := newTemplateNamer(cfg.FilenamePattern)
+		n.rule = rule
+		n.loc = cfg.Location
+		n.compressExt = compressExt
+		return n
+	}
+	return &suffixNamer{
+		path:        cfg.LogPath,
+		rule:        rule,
+		loc:         cfg.Location,
+		compressExt: compressExt,
+		withPath:    isPathSegment(cfg, rule),
+	}
+}
+
+// isPathSegment LogPath 本身是否为分割后的文件
+//
+// 直接写入且规则不生成后缀时（eg: no 规则配合 MaxSize），第0个分段即 LogPath；
+// rename 写入策略以及 CurrentLink 的 LogPath 不是分割后的文件
+func isPathSegment(cfg *RotateWriterConfig, rule RotateRule) bool {
+	if cfg.WriteStrategy == StrategyRename || cfg.CurrentLink {
+		return false
+	}
+	_, suffix := periodSuffix(rule, nowFunc())
+	return len(suffix) == 0
+}
+
+// lastSeq 获取周期文件已存在的最大分段序号
+func lastSeq(namer fileNamer, period string) int {
+	current, ok := namer.Match(period)
+	if !ok {
+		return 0
+	}
+	matches, errGlob := filepath.Glob(namer.Glob())
+	if errGlob != nil {
+		return 0
+	}
+	seq := 0
+	for _, name := range matches {
+		nf, ok := namer.Match(name)
+		if !ok || nf.Period != current.Period {
+			continue
+		}
+		if nf.Seq > seq {
+			seq = nf.Seq
+		}
+	}
+	return seq
+}
+
+// suffixNamer 默认的命名方式，在 LogPath 后追加规则生成的后缀和分段序号
+//
+// eg: test.log.2024-01-02_15, test.log.2024-01-02_15.001
+type suffixNamer struct {
+	path string
+	rule RotateRule
+	loc  *time.Location
+	// 写入器配置的压缩器的扩展名
+	compressExt string
+	// path 本身是否为第0个分段，由写入器管理
+	withPath bool
+}
+
+func (n *suffixNamer) Name(_ time.Time, suffix string, seq int) string {
+	return segmentPath(n.path+suffix, seq)
+}
+
+func (n *suffixNamer) Glob() string {
+	if n.withPath {
+		return n.path + "*"
+	}
+	return n.path + ".*"
+}
+
+func (n *suffixNamer) Match(path string) (namedFile, bool) {
+	name, ext := splitCompressExt(path, n.compressExt)
+	nf, ok := n.match(name)
+	nf.Path, nf.Ext = path, ext
+	return nf, ok
+}
+
+func (n *suffixNamer) match(path string) (namedFile, bool) {
+	if path == n.path {
+		return namedFile{Path: path}, true
+	}
+	if filepath.Dir(path) != filepath.Dir(n.path) || !strings.HasPrefix(path, n.path+".") {
+		return namedFile{}, false
+	}
+	suffix := strings.TrimPrefix(path, n.path)
+	// 优先由规则解析后缀，自定义规则的后缀可以包含字母；
+	// 后缀可以被规则完整解析时，不带分段序号，避免把 .2024 这样的年份当作序号
+	if t, ok := n.rule.Parse(suffix, n.loc); ok {
+		return namedFile{Path: path, Period: suffix, Time: t}, true
+	}
+	period, seq := splitSeq(suffix)
+	if t, ok := n.rule.Parse(period, n.loc); ok {
+		return namedFile{Path: path, Period: period, Seq: seq, Time: t}, true
+	}
+	// 规则无法解析时（eg: AddRotateRule 添加的规则），后缀只能由数字、_ 和 - 组成
+	if !isFilenameMatch(filepath.Base(n.path), filepath.Base(path)) {
+		return namedFile{}, false
+	}
+	return namedFile{Path: path, Period: period, Seq: seq}, true
+}
+
+// templateNamer 按照文件名模板生成文件路径
+//
+// 支持的占位符：
+//
+// %Y 年，%m 月，%d 日，%H 时，%M 分，%S 秒，%% 百分号，使用周期的起始时间
+//
+// {date} 规则生成的文件名后缀（不含开头的 .），{seq} 分段序号（补零到3位）
+//
+// 模板中没有 {seq} 时，分段序号以 .001 的形式插入到扩展名之前，eg: app-20240102-15.001.log
+type templateNamer struct {
+	pattern string
+	// 模板被拆分成的片段，字面量或者占位符
+	tokens []templateToken
+	// 模板中是否包含 {seq}
+	hasSeq bool
+	re     *regexp.Regexp
+	// 模板中表示周期的占位符，与正则表达式中的 period 分组一一对应
+	periodVerbs []string
+	// 用于从 {date} 中解析周期的起始时间，可以为空
+	rule RotateRule
+	loc  *time.Location
+	// 写入器配置的压缩器的扩展名
+	compressExt string
+}
+
+type templateToken struct {
+	literal string
+	// 占位符，为空时为字面量
+	verb string
+}
+
+var templateVerbs = map[string]struct {
+	layout string
+	regexp string
+}{
+	"%Y":     {layout: "2006", regexp: `\d{4}`},
+	"%m":     {layout: "01", regexp: `\d{2}`},
+	"%d":     {layout: "02", regexp: `\d{2}`},
+	"%H":     {layout: "15", regexp: `\d{2}`},
+	"%M":     {layout: "04", regexp: `\d{2}`},
+	"%S":     {layout: "05", regexp: `\d{2}`},
+	"{date}": {regexp: `[\d_-]+`},
+	"{seq}":  {regexp: `\d+`},
+}
+
+// newTemplateNamer 解析文件名模板
+func newTemplateNamer(pattern string) *templateNamer {
+	pattern = filepath.Clean(filepath.FromSlash(pattern))
+	n := &templateNamer{pattern: pattern}
+	var literal strings.Builder
+	for i := 0; i < len(pattern); {
+		verb := ""
+		for v := range templateVerbs {
+			if strings.HasPrefix(pattern[i:], v) {
+				verb = v
+				break
+			}
+		}
+		if verb == "" {
+			// %% 转义为 %
+			if strings.HasPrefix(pattern[i:], "%%") {
+				literal.WriteByte('%')
+				i += 2
+				continue
+			}
+			literal.WriteByte(pattern[i])
+			i++
+			continue
+		}
+		if literal.Len() > 0 {
+			n.tokens = append(n.tokens, templateToken{literal: literal.String()})
+			literal.Reset()
+		}
+		n.tokens = append(n.tokens, templateToken{verb: verb})
+		if verb == "{seq}" {
+			n.hasSeq = true
+		} else {
+			n.periodVerbs = append(n.periodVerbs, verb)
+		}
+		i += len(verb)
+	}
+	if literal.Len() > 0 {
+		n.tokens = append(n.tokens, templateToken{literal: literal.String()})
+	}
+	n.re = regexp.MustCompile("^" + n.render(
+		func(s string) string { return regexp.QuoteMeta(s) },
+		func(verb string) string {
+			if verb == "{seq}" {
+				return "(?P<seq>" + templateVerbs[verb].regexp + ")"
+			}
+			return "(?P<period>" + templateVerbs[verb].regexp + ")"
+		},
+		`(?:\.(?P<seq>\d+))?`,
+	) + "$")
+	return n
+}
+
+// render 依次渲染模板的片段，没有 {seq} 时在扩展名之前插入 seq
+func (n *templateNamer) render(literal func(string) string, verb func(string) string, seq string) string {
+	var b strings.Builder
+	for _, token := range n.tokens {
+		if token.verb != "" {
+			b.WriteString(verb(token.verb))
+			continue
+		}
+		b.WriteString(literal(token.literal))
+	}
+	ret := b.String()
+	if n.hasSeq || seq == "" {
+		return ret
+	}
+	// 在最后一个片段的扩展名之前插入
+	last := n.tokens[len(n.tokens)-1]
+	if last.verb != "" {
+		return ret + seq
+	}
+	ext := filepath.Ext(last.literal)
+	if ext == "" {
+		return ret + seq
+	}
+	rendered := literal(ext)
+	return strings.TrimSuffix(ret, rendered) + seq + rendered
+}
+
+func (n *templateNamer) Name(start time.Time, suffix string, seq int) string {
+	seqPart := ""
+	if seq > 0 {
+		seqPart = "." + formatSeq(seq)
+	}
+	return n.render(
+		func(s string) string { return s },
+		func(verb string) string {
+			switch verb {
+			case "{date}":
+				return strings.TrimPrefix(suffix, ".")
+			case "{seq}":
+				return formatSeq(seq)
+			default:
+				return start.Format(templateVerbs[verb].layout)
+			}
+		},
+		seqPart,
+	)
+}
+
+func (n *templateNamer) Glob() string {
+	// 不含 {seq} 时同样匹配插入了分段序号的文件，eg: app.log 的分段 app.001.log
+	glob := n.render(
+		func(s string) string { return s },
+		func(string) string { return "*" },
+		"*",
+	)
+	// 同时匹配压缩后的文件
+	glob += "*"
+	// 相邻的占位符合并为一个 *
+	for strings.Contains(glob, "**") {
+		glob = strings.ReplaceAll(glob, "**", "*")
+	}
+	return glob
+}
+
+func (n *templateNamer) Match(path string) (namedFile, bool) {
+	name, ext := splitCompressExt(filepath.Clean(path), n.compressExt)
+	matches := n.re.FindStringSubmatch(name)
+	if matches == nil {
+		return namedFile{}, false
+	}
+	nf := namedFile{Path: path, Ext: ext}
+	var period strings.Builder
+	values := make([]string, 0, len(n.periodVerbs))
+	for i, name := range n.re.SubexpNames() {
+		switch name {
+		case "period":
+			period.WriteString(matches[i])
+			period.WriteByte('|')
+			values = append(values, matches[i])
+		case "seq":
+			if matches[i] != "" {
+				nf.Seq, _ = strconv.Atoi(matches[i])
+			}
+		}
+	}
+	nf.Period = period.String()
+	nf.Time = n.periodTime(values)
+	return nf, true
+}
+
+// periodTime 根据周期占位符匹配到的值解析周期的起始时间，优先使用 {date}，无法解析时返回零值
+func (n *templateNamer) periodTime(values []string) time.Time {
+	loc := n.loc
+	if loc == nil {
+		loc = time.Local
+	}
+	var layout, value strings.Builder
+	for i, verb := range n.periodVerbs {
+		if verb == "{date}" {
+			if n.rule == nil {
+				continue
+			}
+			if t, ok := n.rule.Parse("."+values[i], loc); ok {
+				return t
+			}
+			continue
+		}
+		layout.WriteString(templateVerbs[verb].layout + " ")
+		value.WriteString(values[i] + " ")
+	}
+	if layout.Len() == 0 {
+		return time.Time{}
+	}
+	t, err := time.ParseInLocation(layout.String(), value.String(), loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
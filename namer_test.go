@@ -0,0 +1,113 @@
+package rotw
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func Test_templateNamer(t *testing.T) {
+	start := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	cases := []struct {
+		pattern string
+		suffix  string
+		seq     int
+		name    string
+		glob    string
+	}{
+		{"log/app-%Y%m%d-%H.log", ".2024-01-02_15", 0, "log/app-20240102-15.log", "log/app-*-*.log*"},
+		{"log/app-%Y%m%d-%H.log", ".2024-01-02_15", 2, "log/app-20240102-15.002.log", "log/app-*-*.log*"},
+		{"log/app.{date}.{seq}.log", ".2024-01-02_15", 3, "log/app.2024-01-02_15.003.log", "log/app.*.*.log*"},
+		{"log/%Y/%m/app.log", ".2024-01-02", 0, "log/2024/01/app.log", "log/*/*/app*.log*"},
+		{"log/app.log.%Y%m%d", ".2024-01-02", 1, "log/app.log.20240102.001", "log/app.log.*"},
+		{"log/100%%-%Y.log", ".2024", 0, "log/100%-2024.log", "log/100%-*.log*"},
+	}
+	for _, c := range cases {
+		n := newTemplateNamer(c.pattern)
+		name := n.Name(start, c.suffix, c.seq)
+		if name != filepath.FromSlash(c.name) {
+			t.Errorf("%s: Name() = %s, want %s", c.pattern, name, c.name)
+		}
+		if glob := n.Glob(); glob != filepath.FromSlash(c.glob) {
+			t.Errorf("%s: Glob() = %s, want %s", c.pattern, glob, c.glob)
+		}
+		if matched, _ := filepath.Match(n.Glob(), name); !matched {
+			t.Errorf("%s: %s does not match glob %s", c.pattern, name, n.Glob())
+		}
+		nf, ok := n.Match(name)
+		if !ok || nf.Seq != c.seq {
+			t.Errorf("%s: Match(%s) = %+v, %v", c.pattern, name, nf, ok)
+		}
+	}
+	n := newTemplateNamer("log/app-%Y%m%d-%H.log")
+	if nf, ok := n.Match(filepath.FromSlash("log/app-20240102-15.3.log.gz")); !ok || nf.Seq != 3 || nf.Ext != ".gz" {
+		t.Errorf("compressed file should match, got %+v", nf)
+	}
+	if nf, _ := n.Match(filepath.FromSlash("log/app-20240102-15.3.log")); !nf.Time.Equal(time.Date(2024, 1, 2, 15, 0, 0, 0, time.Local)) {
+		t.Errorf("unexpected period time %v", nf.Time)
+	}
+	for _, name := range []string{"log/app-2024-15.log", "log/app-20240102-15.log.bak", "log/other-20240102-15.log"} {
+		if _, ok := n.Match(filepath.FromSlash(name)); ok {
+			t.Errorf("%s should not match", name)
+		}
+	}
+}
+
+func Test_filenamePattern_noRule(t *testing.T) {
+	dir := t.TempDir()
+	for _, pattern := range []string{"log/app-%Y%m%d.log", "app.{date}.log"} {
+		if _, err := NewRotateWriterWithOpt("", WithFilenamePattern(filepath.Join(dir, pattern))); err == nil {
+			t.Errorf("%s: pattern with period placeholders should fail without period", pattern)
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*")); len(matches) != 0 {
+		t.Errorf("no file should be written, got %v", matches)
+	}
+	// 不含周期占位符的模板可以不分割文件
+	rw, err := NewRotateWriterWithOpt("", WithFilenamePattern(filepath.Join(dir, "app-{seq}.log")))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+}
+
+func Test_filenamePattern(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "app-%Y%m%d-%H.log")
+	rw, err := NewRotateWriterWithOpt("", WithFilenamePattern(pattern), WithRule("hour"), WithMaxSize(10), WithKeepFiles(2))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	for i := 0; i < 3; i++ {
+		_, _ = rw.Write([]byte("hello world\n"))
+	}
+	r := rw.(*rotateWriter)
+	current := r.rig.Get()
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if filepath.Ext(current.RotatePath) != ".log" || current.Seq != 2 {
+		t.Errorf("unexpected rotate info: %+v", current)
+	}
+	files, err := getExpireFiles(r.namer, CountPolicy(2), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一秒内创建的文件按分段序号排序，最早的分段过期
+	matches, _ := filepath.Glob(r.namer.Glob())
+	sort.Slice(matches, func(i, j int) bool {
+		a, _ := r.namer.Match(matches[i])
+		b, _ := r.namer.Match(matches[j])
+		return lessNamedFile(a, b)
+	})
+	if len(matches) != 3 || len(files) != 1 || files[0] != matches[0] {
+		t.Errorf("unexpected expire files %v of %v", files, matches)
+	}
+	if _, errStat := os.Stat(filepath.Join(dir, "app.log")); !os.IsNotExist(errStat) {
+		t.Error("no file should be written without the pattern")
+	}
+}
@@ -0,0 +1,170 @@
+package rotw
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// ManagedFile 由写入器管理的文件
+type ManagedFile struct {
+	// 文件路径
+	Path string
+	// 从文件名中解析出的周期起始时间，无法解析时为零值
+	Time time.Time
+	// 文件修改时间
+	ModTime time.Time
+	// 文件大小
+	Size int64
+	// 周期内的分段序号
+	Seq int
+	// 是否为正在写入的文件，正在写入的文件即使被返回也不会删除
+	Active bool
+}
+
+// sortTime 文件排序使用的时间，文件名中无法解析出时间时使用修改时间
+func (f ManagedFile) sortTime() time.Time {
+	if !f.Time.IsZero() {
+		return f.Time
+	}
+	return f.ModTime
+}
+
+// RetentionPolicy 文件保留策略，决定需要删除哪些文件
+type RetentionPolicy interface {
+	// Expire 从 files 中选出需要删除的文件
+	//
+	// files 按照周期起始时间从旧到新排列，无法从文件名解析时间的文件按修改时间排列；now 为当前时间
+	Expire(files []ManagedFile, now time.Time) []ManagedFile
+}
+
+// RetentionFunc 函数形式的文件保留策略
+type RetentionFunc func(files []ManagedFile, now time.Time) []ManagedFile
+
+func (f RetentionFunc) Expire(files []ManagedFile, now time.Time) []ManagedFile {
+	return f(files, now)
+}
+
+// CountPolicy 最多保留 keep 个文件，包括正在写入的文件，删除更早的文件
+func CountPolicy(keep int) RetentionPolicy {
+	return RetentionFunc(func(files []ManagedFile, _ time.Time) []ManagedFile {
+		if keep <= 0 || len(files) <= keep {
+			return nil
+		}
+		return files[:len(files)-keep]
+	})
+}
+
+// AgePolicy 删除周期起始时间早于 now-maxAge 的文件，无法从文件名解析时间的文件不删除
+func AgePolicy(maxAge time.Duration) RetentionPolicy {
+	return RetentionFunc(func(files []ManagedFile, now time.Time) []ManagedFile {
+		if maxAge <= 0 {
+			return nil
+		}
+		ret := make([]ManagedFile, 0)
+		for _, f := range files {
+			if !f.Time.IsZero() && now.Sub(f.Time) > maxAge {
+				ret = append(ret, f)
+			}
+		}
+		return ret
+	})
+}
+
+// SizePolicy 所有文件的总大小超过 maxTotalSize 时，从最早的文件开始删除，正在写入的文件同样计入总大小
+func SizePolicy(maxTotalSize int64) RetentionPolicy {
+	return RetentionFunc(func(files []ManagedFile, _ time.Time) []ManagedFile {
+		if maxTotalSize <= 0 {
+			return nil
+		}
+		// 从最新的文件开始累计大小，超过总大小上限的文件及更早的文件都需要删除
+		var total int64
+		for i := len(files) - 1; i >= 0; i-- {
+			total += files[i].Size
+			if total > maxTotalSize {
+				return files[:i+1]
+			}
+		}
+		return nil
+	})
+}
+
+// All 组合多个文件保留策略，任一策略选中的文件都会删除，即最严格的策略生效
+func All(policies ...RetentionPolicy) RetentionPolicy {
+	return RetentionFunc(func(files []ManagedFile, now time.Time) []ManagedFile {
+		expired := make(map[string]struct{})
+		for _, policy := range policies {
+			for _, f := range policy.Expire(files, now) {
+				expired[f.Path] = struct{}{}
+			}
+		}
+		// 按照 files 的顺序返回，先删除更早的文件
+		ret := make([]ManagedFile, 0, len(expired))
+		for _, f := range files {
+			if _, ok := expired[f.Path]; ok {
+				ret = append(ret, f)
+			}
+		}
+		return ret
+	})
+}
+
+// RetentionTier 分级保留策略中的一级
+type RetentionTier struct {
+	// 该级别覆盖的时长，周期起始时间在 now-Within 之后的文件属于该级别
+	Within time.Duration
+	// 该级别内每隔多久保留一个文件，每个间隔内保留最早的文件，0 表示保留所有文件
+	//
+	// 间隔按照文件时区的日历对齐，eg: time.Hour 在整点，24 * time.Hour 在零点
+	Every time.Duration
+}
+
+// TieredPolicy 祖父-父-子分级保留策略，近期的文件保留得更密集，较早的文件逐级稀疏
+//
+// 文件属于 Within 最小的且覆盖该文件的级别，不属于任何级别的文件删除；无法从文件名解析时间的文件不删除
+//
+// eg: 2小时内保留所有文件，2天内每小时保留一个，30天内每天保留一个
+//
+//	TieredPolicy(
+//		RetentionTier{Within: 2 * time.Hour},
+//		RetentionTier{Within: 48 * time.Hour, Every: time.Hour},
+//		RetentionTier{Within: 30 * 24 * time.Hour, Every: 24 * time.Hour},
+//	)
+func TieredPolicy(tiers ...RetentionTier) RetentionPolicy {
+	tiers = slices.Clone(tiers)
+	slices.SortFunc(tiers, func(a, b RetentionTier) int {
+		return cmp.Compare(a.Within, b.Within)
+	})
+	return RetentionFunc(func(files []ManagedFile, now time.Time) []ManagedFile {
+		// 每个级别中已经保留了文件的间隔
+		kept := make([]map[int64]struct{}, len(tiers))
+		ret := make([]ManagedFile, 0)
+		for _, f := range files {
+			if f.Time.IsZero() {
+				continue
+			}
+			idx := slices.IndexFunc(tiers, func(tier RetentionTier) bool {
+				return now.Sub(f.Time) <= tier.Within
+			})
+			if idx < 0 {
+				ret = append(ret, f)
+				continue
+			}
+			every := tiers[idx].Every
+			if every <= 0 {
+				continue
+			}
+			// files 从旧到新排列，每个间隔内第一个出现的文件即最早的文件
+			bucket := durationStart(f.Time, every).Unix()
+			if kept[idx] == nil {
+				kept[idx] = make(map[int64]struct{})
+			}
+			if _, ok := kept[idx][bucket]; ok {
+				ret = append(ret, f)
+				continue
+			}
+			kept[idx][bucket] = struct{}{}
+		}
+		return ret
+	})
+}
@@ -0,0 +1,84 @@
+package rotw
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_RetentionPolicy(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	files := make([]ManagedFile, 0)
+	for day := 1; day <= 10; day++ {
+		files = append(files, ManagedFile{
+			Path:   time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC).Format("test.log.2006-01-02"),
+			Time:   time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC),
+			Size:   10,
+			Active: day == 10,
+		})
+	}
+	// 保留每周一的文件
+	keepMonday := RetentionFunc(func(files []ManagedFile, now time.Time) []ManagedFile {
+		ret := make([]ManagedFile, 0)
+		for _, f := range AgePolicy(48*time.Hour).Expire(files, now) {
+			if f.Time.Weekday() != time.Monday {
+				ret = append(ret, f)
+			}
+		}
+		return ret
+	})
+	cases := []struct {
+		policy RetentionPolicy
+		want   []int
+	}{
+		{CountPolicy(0), []int{}},
+		{CountPolicy(8), []int{1, 2}},
+		{AgePolicy(72 * time.Hour), []int{1, 2, 3, 4, 5, 6, 7}},
+		{SizePolicy(35), []int{1, 2, 3, 4, 5, 6, 7}},
+		{All(CountPolicy(8), AgePolicy(7*24*time.Hour), SizePolicy(0)), []int{1, 2, 3}},
+		{All(CountPolicy(9), SizePolicy(75)), []int{1, 2, 3}},
+		{keepMonday, []int{2, 3, 4, 5, 6, 7}},
+	}
+	for i, c := range cases {
+		got := make([]int, 0)
+		for _, f := range c.policy.Expire(files, now) {
+			got = append(got, f.Time.Day())
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("case %d: Expire() = %v, want %v", i, got, c.want)
+		}
+	}
+}
+
+func Test_TieredPolicy(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	files := make([]ManagedFile, 0)
+	for tm := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC); !tm.After(now); tm = tm.Add(30 * time.Minute) {
+		files = append(files, ManagedFile{Path: tm.Format("test.log.2006-01-02_1504"), Time: tm})
+	}
+	// 无法解析时间的文件不删除
+	files = append([]ManagedFile{{Path: "test.log.1"}}, files...)
+	policy := TieredPolicy(
+		RetentionTier{Within: 72 * time.Hour, Every: 24 * time.Hour},
+		RetentionTier{Within: 2 * time.Hour},
+		RetentionTier{Within: 24 * time.Hour, Every: time.Hour},
+	)
+	expired := make(map[string]bool)
+	for _, f := range policy.Expire(files, now) {
+		expired[f.Path] = true
+	}
+	kept := make([]string, 0)
+	for _, f := range files {
+		if !expired[f.Path] {
+			kept = append(kept, f.Path)
+		}
+	}
+	// 2小时内5个，24小时内每小时1个共22个，72小时内每天1个共3个
+	if len(kept) != 31 {
+		t.Fatalf("unexpected kept files %d: %v", len(kept), kept)
+	}
+	want := []string{"test.log.1", "test.log.2024-01-07_1200", "test.log.2024-01-08_0000", "test.log.2024-01-09_0000", "test.log.2024-01-09_1200", "test.log.2024-01-09_1300"}
+	if !reflect.DeepEqual(kept[:len(want)], want) {
+		t.Errorf("kept = %v, want prefix %v", kept, want)
+	}
+}
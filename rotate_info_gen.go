@@ -2,16 +2,23 @@ package rotw
 
 import (
 	"context"
-	"errors"
-	"fmt"
+	"sync"
 	"time"
 )
 
 type rotateInfo struct {
 	// 原始文件路径
 	RawPath string
-	// 分割后的文件路径
+	// 当前周期的文件路径，不含分段序号
+	PeriodPath string
+	// 分割后的文件路径，包含分段序号
 	RotatePath string
+	// 当前周期内的分段序号，0 表示不带序号
+	Seq int
+	// 当前周期的起始时间，不分割时为零值
+	Start time.Time
+	// 规则生成的文件名后缀
+	Suffix string
 }
 
 // RotateInfoGenerator 文件分割信息生成器
@@ -22,31 +29,71 @@ type RotateInfoGenerator interface {
 	AddCallback(func(rotateInfo))
 	// AddCallbackWithCtx 添加回调函数，并传入生成器的上下文，用于用户控制中断任务
 	AddCallbackWithCtx(func(context.Context, rotateInfo))
+	// Roll 在当前周期内切换到下一个分段，并通知所有回调函数
+	Roll() rotateInfo
 	// Stop 停止生成器，此操作会cancel生成器的上下文，并停止生成器
 	Stop()
 }
 
 type rotateInfoGenerator struct {
 	g Generator
+	// 原始文件路径
+	rawPath string
+	// 文件命名器
+	namer fileNamer
+	mux   sync.Mutex
+	// 当前周期的文件路径
+	period string
+	// 当前周期内的分段序号
+	seq int
 }
 
-// ErrInvalidRule 无效规则错误
-var ErrInvalidRule = errors.New("invalid rule")
-
-// NewRotateInfoGenerator 创建文件分割信息生成器
+// NewRotateInfoGenerator 创建文件分割信息生成器，使用本地时区
 func NewRotateInfoGenerator(rule string, filePath string) (RotateInfoGenerator, error) {
-	if r, ok := defaultRotateRule[rule]; ok {
-		fn := func() any {
-			return rotateInfo{
-				RawPath:    filePath,
-				RotatePath: filePath + r.SuffixFunc(),
-			}
-		}
-		return &rotateInfoGenerator{
-			g: NewGenerator(r.Span, fn),
-		}, nil
+	return newRotateInfoGenerator(&RotateWriterConfig{
+		Rule:     rule,
+		LogPath:  filePath,
+		Location: time.Local,
+	})
+}
+
+// newRotateInfoGenerator 根据写入器配置创建文件分割信息生成器
+func newRotateInfoGenerator(cfg *RotateWriterConfig) (RotateInfoGenerator, error) {
+	loc := cfg.Location
+	rule, err := cfg.rotateRule()
+	if err != nil {
+		return nil, err
+	}
+	rig := &rotateInfoGenerator{
+		rawPath: cfg.LogPath,
+		namer:   newFileNamer(cfg, rule),
+	}
+	fn := func() any {
+		// 周期的起始时间只计算一次，文件名后缀由起始时间生成
+		start, suffix := periodSuffix(rule, nowFunc().In(loc))
+		return rig.gen(start, suffix)
+	}
+	rig.g = NewGeneratorWithNext(inLocation(rule.Next, loc), fn)
+	return rig, nil
+}
+
+// gen 生成文件分割信息，周期变化时从该周期已存在的最大分段继续
+func (r *rotateInfoGenerator) gen(start time.Time, suffix string) rotateInfo {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	period := r.namer.Name(start, suffix, 0)
+	if period != r.period {
+		r.period = period
+		r.seq = lastSeq(r.namer, period)
+	}
+	return rotateInfo{
+		RawPath:    r.rawPath,
+		PeriodPath: period,
+		RotatePath: r.namer.Name(start, suffix, r.seq),
+		Seq:        r.seq,
+		Start:      start,
+		Suffix:     suffix,
 	}
-	return nil, ErrInvalidRule
 }
 
 func (r *rotateInfoGenerator) Get() rotateInfo {
@@ -67,88 +114,13 @@ func (r *rotateInfoGenerator) AddCallbackWithCtx(fn func(context.Context, rotate
 	r.g.AddCallbackWithCtx(f)
 }
 
-func (r *rotateInfoGenerator) Stop() {
-	r.g.Stop()
-}
-
-type rotateRule struct {
-	Span       time.Duration
-	SuffixFunc func() string
-}
-
-// AddRotateRule 添加自定义时间分割规则
-//
-// 默认存在的规则：
-//
-// no: 不分割
-//
-// 1min: 每分钟分割一次，文件名格式：2006-01-02_1504'
-//
-// 5min: 每五分钟分割一次，文件名格式：2006-01-02_15xx
-//
-// 10min: 每十分钟分割一次，文件名格式：2006-01-02_15xx
-//
-// 15min: 每十五分钟分割一次，文件名格式：2006-01-02_15xx
-//
-// 30min: 每三十分钟分割一次，文件名格式：2006-01-02_15xx
-//
-// hour: 每小时分割一次，文件名格式：2006-01-02_15
-//
-// day: 每天分割一次，文件名格式：2006-01-02
-func AddRotateRule(name string, span time.Duration, fn func() string) error {
-	if _, ok := defaultRotateRule[name]; ok {
-		return errors.New("rule already exists")
-	}
-	defaultRotateRule[name] = &rotateRule{
-		Span:       span,
-		SuffixFunc: fn,
-	}
-	return nil
+func (r *rotateInfoGenerator) Roll() rotateInfo {
+	r.mux.Lock()
+	r.seq++
+	r.mux.Unlock()
+	return r.g.Trigger().(rotateInfo)
 }
 
-var defaultRotateRule = map[string]*rotateRule{
-	"no": {
-		Span:       0,
-		SuffixFunc: func() string { return "" },
-	},
-	"1min": {
-		Span:       time.Minute,
-		SuffixFunc: func() string { return "." + nowFunc().Format("2006-01-02_1504") },
-	},
-	"5min": {
-		Span: time.Minute * 5,
-		SuffixFunc: func() string {
-			now := nowFunc()
-			return "." + now.Format("2006-01-02_15") + fmt.Sprintf("%02d", now.Minute()/5*5)
-		},
-	},
-	"10min": {
-		Span: time.Minute * 10,
-		SuffixFunc: func() string {
-			now := nowFunc()
-			return "." + now.Format("2006-01-02_15") + fmt.Sprintf("%02d", now.Minute()/10*10)
-		},
-	},
-	"15min": {
-		Span: time.Minute * 15,
-		SuffixFunc: func() string {
-			now := nowFunc()
-			return "." + now.Format("2006-01-02_15") + fmt.Sprintf("%02d", now.Minute()/15*15)
-		},
-	},
-	"30min": {
-		Span: time.Minute * 30,
-		SuffixFunc: func() string {
-			now := nowFunc()
-			return "." + now.Format("2006-01-02_15") + fmt.Sprintf("%02d", now.Minute()/30*30)
-		},
-	},
-	"hour": {
-		Span:       time.Hour,
-		SuffixFunc: func() string { return "." + nowFunc().Format("2006-01-02_15") },
-	},
-	"day": {
-		Span:       time.Hour * 24,
-		SuffixFunc: func() string { return "." + nowFunc().Format("2006-01-02") },
-	},
+func (r *rotateInfoGenerator) Stop() {
+	r.g.Stop()
 }
@@ -2,6 +2,7 @@ package rotw
 
 import (
 	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -13,4 +14,90 @@ func Test_AddRotateRule(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+
+	// span 为0时不按时间分割，使用 fn 生成的后缀
+	if err = AddRotateRule("zero-span", 0, func() string { return ".custom" }); err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	rw, err := NewRotateWriterWithOpt(logPath, WithRule("zero-span"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := rw.(*rotateWriter).rig.Get()
+	if err = rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info.RotatePath != logPath+".custom" || !info.Start.IsZero() {
+		t.Errorf("unexpected info: %+v", info)
+	}
+}
+
+func Test_RotateInfoGenerator_Roll(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	rig, err := NewRotateInfoGenerator("hour", logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rig.Stop()
+	info := rig.Get()
+	if info.Seq != 0 || info.RotatePath != info.PeriodPath {
+		t.Fatalf("unexpected initial info: %+v", info)
+	}
+	rolled := rig.Roll()
+	if rolled.Seq != 1 || rolled.RotatePath != info.PeriodPath+".001" {
+		t.Fatalf("unexpected rolled info: %+v", rolled)
+	}
+	if rig.Get() != rolled {
+		t.Fatalf("Get() should return rolled info, got %+v", rig.Get())
+	}
+}
+
+func Test_newRotateInfoGenerator_location(t *testing.T) {
+	// 东八区 2024-01-03 07:30，对应 UTC 2024-01-02 23:30
+	now := time.Date(2024, 1, 3, 7, 30, 0, 0, time.FixedZone("CST", 8*3600))
+	setNowFunc(func() time.Time { return now })
+	defer setNowFunc(time.Now)
+
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	rig, err := newRotateInfoGenerator(&RotateWriterConfig{Rule: "day", LogPath: logPath, Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rig.Stop()
+	if got, want := rig.Get().RotatePath, logPath+".2024-01-02"; got != want {
+		t.Errorf("RotatePath = %s, want %s", got, want)
+	}
+	if got, _ := rig.(*rotateInfoGenerator).g.(*generator).next(); got != 30*time.Minute {
+		t.Errorf("next() = %v, want %v", got, 30*time.Minute)
+	}
+}
+
+func Test_NewRotateInfoGenerator_duration(t *testing.T) {
+	now := time.Date(2024, 1, 3, 10, 47, 30, 0, time.Local)
+	setNowFunc(func() time.Time { return now })
+	defer setNowFunc(time.Now)
+
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	cases := map[string]string{
+		"90s":   ".2024-01-03_104630",
+		"20min": ".2024-01-03_1040",
+		"2h":    ".2024-01-03_10",
+		"3d":    ".2024-01-03",
+	}
+	for rule, suffix := range cases {
+		rig, err := NewRotateInfoGenerator(rule, logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rig.Get().RotatePath; got != logPath+suffix {
+			t.Errorf("%s: RotatePath = %s, want %s", rule, got, logPath+suffix)
+		}
+		rig.Stop()
+	}
+	for _, rule := range []string{"0h", "2x", "h", "-1d"} {
+		if _, err := NewRotateInfoGenerator(rule, logPath); err != ErrInvalidRule {
+			t.Errorf("%s: err = %v, want %v", rule, err, ErrInvalidRule)
+		}
+	}
 }
@@ -0,0 +1,289 @@
+package rotw
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateRule 文件分割规则，决定文件在什么时间点分割以及分割后的文件名后缀
+//
+// 传入的时间都已经转换到写入器配置的时区
+type RotateRule interface {
+	// Start 计算 t 所在周期的起始时间
+	Start(t time.Time) time.Time
+	// Next 计算 t 之后下一个周期的起始时间，返回零值表示不再分割
+	Next(t time.Time) time.Time
+	// Suffix 根据周期的起始时间生成文件名后缀，eg: .2024-01-02
+	Suffix(periodStart time.Time) string
+	// Parse 从文件名后缀中解析出周期的起始时间，无法解析时返回 false
+	Parse(suffix string, loc *time.Location) (time.Time, bool)
+}
+
+// ErrInvalidRule 无效规则错误
+var ErrInvalidRule = errors.New("invalid rule")
+
+var (
+	rotateRuleMux     sync.RWMutex
+	defaultRotateRule = map[string]RotateRule{
+		"no":    noRule{},
+		"1min":  NewDurationRule(time.Minute),
+		"5min":  NewDurationRule(time.Minute * 5),
+		"10min": NewDurationRule(time.Minute * 10),
+		"15min": NewDurationRule(time.Minute * 15),
+		"30min": NewDurationRule(time.Minute * 30),
+		"hour":  NewDurationRule(time.Hour),
+		"day":   NewDurationRule(time.Hour * 24),
+		"week":  &calendarRule{start: weekStart, next: weekNext, layout: "2006-01-02"},
+		"month": &calendarRule{start: monthStart, next: monthNext, layout: "2006-01"},
+		"year":  &calendarRule{start: yearStart, next: yearNext, layout: "2006"},
+	}
+)
+
+// AddRotateRule 添加自定义时间分割规则
+//
+// 默认存在的规则：
+//
+// no: 不分割
+//
+// 1min: 每分钟分割一次，文件名格式：2006-01-02_1504'
+//
+// 5min: 每五分钟分割一次，文件名格式：2006-01-02_15xx
+//
+// 10min: 每十分钟分割一次，文件名格式：2006-01-02_15xx
+//
+// 15min: 每十五分钟分割一次，文件名格式：2006-01-02_15xx
+//
+// 30min: 每三十分钟分割一次，文件名格式：2006-01-02_15xx
+//
+// hour: 每小时分割一次，文件名格式：2006-01-02_15
+//
+// day: 每天分割一次，文件名格式：2006-01-02
+//
+// week: 每周一分割一次，文件名格式：2006-01-02
+//
+// month: 每月1号分割一次，文件名格式：2006-01
+//
+// year: 每年1月1号分割一次，文件名格式：2006
+//
+// 未注册的规则可以直接使用时长，eg: 90s, 45min, 2h, 3d，文件名格式根据时长的粒度选择：
+//
+// 秒级：2006-01-02_150405，分钟级：2006-01-02_1504，小时级：2006-01-02_15，天级：2006-01-02
+//
+// 默认规则按照配置时区的日历对齐，夏令时切换时依然在整点和零点分割；
+//
+// 自定义规则按照 span 对齐配置时区，文件名后缀由 fn 自行生成；span <= 0 时不按时间分割，文件名后缀固定使用 fn 的返回值；
+// 需要完全控制分割时间和文件名时，使用 WithRotateRule
+func AddRotateRule(name string, span time.Duration, fn func() string) error {
+	rotateRuleMux.Lock()
+	defer rotateRuleMux.Unlock()
+	if _, ok := defaultRotateRule[name]; ok {
+		return errors.New("rule already exists")
+	}
+	var rule RotateRule = noRule{}
+	if span > 0 {
+		rule = NewDurationRule(span)
+	}
+	defaultRotateRule[name] = &customRule{
+		RotateRule: rule,
+		fn:         fn,
+	}
+	return nil
+}
+
+// ParseRotateRule 解析规则名称，依次尝试已注册的规则、时长和 cron 表达式
+func ParseRotateRule(rule string) (RotateRule, error) {
+	rotateRuleMux.RLock()
+	r, ok := defaultRotateRule[rule]
+	rotateRuleMux.RUnlock()
+	if ok {
+		return r, nil
+	}
+	if span, ok := parseDurationRule(rule); ok {
+		return NewDurationRule(span), nil
+	}
+	if r, err := NewCronRule(rule); err == nil {
+		return r, nil
+	}
+	return nil, ErrInvalidRule
+}
+
+// periodSuffix 获取 now 所在周期的起始时间和文件名后缀
+func periodSuffix(rule RotateRule, now time.Time) (time.Time, string) {
+	start := rule.Start(now)
+	return start, rule.Suffix(start)
+}
+
+// parseLayout 按照时间格式解析文件名后缀
+func parseLayout(layout string, suffix string, loc *time.Location) (time.Time, bool) {
+	if !strings.HasPrefix(suffix, ".") {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(layout, suffix[1:], loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// noRule 不分割文件
+type noRule struct{}
+
+func (noRule) Start(time.Time) time.Time { return time.Time{} }
+
+func (noRule) Next(time.Time) time.Time { return time.Time{} }
+
+func (noRule) Suffix(time.Time) string { return "" }
+
+func (noRule) Parse(string, *time.Location) (time.Time, bool) { return time.Time{}, false }
+
+// durationRule 按照固定时长分割文件
+type durationRule struct {
+	span   time.Duration
+	layout string
+}
+
+// NewDurationRule 创建按照固定时长分割的规则，文件名使用周期的起始时间，格式根据时长的粒度选择
+func NewDurationRule(span time.Duration) RotateRule {
+	return &durationRule{
+		span:   span,
+		layout: durationLayout(span),
+	}
+}
+
+func (r *durationRule) Start(t time.Time) time.Time {
+	return durationStart(t, r.span)
+}
+
+func (r *durationRule) Next(t time.Time) time.Time {
+	return durationNext(r.span)(t)
+}
+
+func (r *durationRule) Suffix(periodStart time.Time) string {
+	return "." + periodStart.Format(r.layout)
+}
+
+func (r *durationRule) Parse(suffix string, loc *time.Location) (time.Time, bool) {
+	return parseLayout(r.layout, suffix, loc)
+}
+
+var durationRuleRegexp = regexp.MustCompile(`^(\d+)(s|m|min|h|d)$`)
+
+// parseDurationRule 解析时长规则，eg: 90s, 45min, 2h, 3d
+func parseDurationRule(rule string) (time.Duration, bool) {
+	matches := durationRuleRegexp.FindStringSubmatch(rule)
+	if matches == nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	unit := time.Second
+	switch matches[2] {
+	case "m", "min":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = day
+	}
+	// 时长溢出时会变为负数，导致下一次分割时间早于当前时间
+	if n > math.MaxInt64/int64(unit) {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// durationLayout 根据周期的粒度选择文件名格式，保证周期的起始时间可以完整表示
+func durationLayout(span time.Duration) string {
+	switch {
+	case span%day == 0:
+		return "2006-01-02"
+	case span%time.Hour == 0:
+		return "2006-01-02_15"
+	case span%time.Minute == 0:
+		return "2006-01-02_1504"
+	default:
+		return "2006-01-02_150405"
+	}
+}
+
+// calendarRule 按照周、月、年等日历单位分割文件
+type calendarRule struct {
+	start  func(time.Time) time.Time
+	next   func(time.Time) time.Time
+	layout string
+}
+
+func (r *calendarRule) Start(t time.Time) time.Time {
+	return r.start(t)
+}
+
+func (r *calendarRule) Next(t time.Time) time.Time {
+	return r.next(t)
+}
+
+func (r *calendarRule) Suffix(periodStart time.Time) string {
+	return "." + periodStart.Format(r.layout)
+}
+
+func (r *calendarRule) Parse(suffix string, loc *time.Location) (time.Time, bool) {
+	return parseLayout(r.layout, suffix, loc)
+}
+
+// cronRule 按照 cron 表达式分割文件，文件名使用周期的起始时间，格式：2006-01-02_1504
+type cronRule struct {
+	sched *cronSchedule
+}
+
+// NewCronRule 解析标准5段式 cron 表达式创建分割规则，eg: "0 */6 * * *"
+func NewCronRule(expr string) (RotateRule, error) {
+	sched, err := parseCron(expr)
+	if err != nil {
+		return nil, err
+	}
+	// 永远不会触发的表达式视为无效规则
+	now := nowFunc()
+	if sched.prev(now).IsZero() && sched.next(now).IsZero() {
+		return nil, ErrInvalidRule
+	}
+	return &cronRule{sched: sched}, nil
+}
+
+func (r *cronRule) Start(t time.Time) time.Time {
+	return r.sched.prev(t)
+}
+
+func (r *cronRule) Next(t time.Time) time.Time {
+	return r.sched.next(t)
+}
+
+func (r *cronRule) Suffix(periodStart time.Time) string {
+	if periodStart.IsZero() {
+		return ""
+	}
+	return "." + periodStart.Format(cronLayout)
+}
+
+func (r *cronRule) Parse(suffix string, loc *time.Location) (time.Time, bool) {
+	return parseLayout(cronLayout, suffix, loc)
+}
+
+// customRule 通过 AddRotateRule 添加的规则，按照 span 对齐，文件名后缀由用户函数生成
+type customRule struct {
+	RotateRule
+	fn func() string
+}
+
+func (r *customRule) Suffix(time.Time) string {
+	return r.fn()
+}
+
+func (r *customRule) Parse(string, *time.Location) (time.Time, bool) {
+	return time.Time{}, false
+}
@@ -0,0 +1,143 @@
+package rotw
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_RotateRule_Parse(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	now := time.Date(2024, 1, 3, 10, 47, 30, 0, loc)
+	for _, name := range []string{"1min", "5min", "hour", "day", "week", "month", "year", "90s", "2h", "3d", "0 */6 * * *"} {
+		rule, err := ParseRotateRule(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		start, suffix := periodSuffix(rule, now)
+		got, ok := rule.Parse(suffix, loc)
+		if !ok || !got.Equal(start) {
+			t.Errorf("%s: Parse(%q) = %v, %v, want %v", name, suffix, got, ok, start)
+		}
+		if next := rule.Next(now); !next.After(now) || rule.Start(next) != next {
+			t.Errorf("%s: Next(%v) = %v is not a period start", name, now, next)
+		}
+	}
+	if _, ok := defaultRotateRule["no"].Parse("", loc); ok {
+		t.Error("no rule should not parse any suffix")
+	}
+}
+
+// evenHourRule 只在偶数小时分割的测试规则
+type evenHourRule struct{}
+
+func (evenHourRule) Start(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()/2*2, 0, 0, 0, t.Location())
+}
+
+func (r evenHourRule) Next(t time.Time) time.Time {
+	return r.Start(t).Add(2 * time.Hour)
+}
+
+func (evenHourRule) Suffix(periodStart time.Time) string {
+	return ".even" + periodStart.Format("2006010215")
+}
+
+func (evenHourRule) Parse(suffix string, loc *time.Location) (time.Time, bool) {
+	if !strings.HasPrefix(suffix, ".even") {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation("2006010215", strings.TrimPrefix(suffix, ".even"), loc)
+	return t, err == nil
+}
+
+func Test_WithRotateRule(t *testing.T) {
+	now := time.Date(2024, 1, 3, 11, 0, 0, 0, time.UTC)
+	setNowFunc(func() time.Time { return now })
+	defer setNowFunc(time.Now)
+
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	cfg := &RotateWriterConfig{LogPath: logPath, Rule: "invalid", Location: time.UTC}
+	WithRotateRule(evenHourRule{})(cfg)
+	rig, err := newRotateInfoGenerator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rig.Stop()
+	info := rig.Get()
+	if info.RotatePath != logPath+".even2024010310" || !info.Start.Equal(time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected info: %+v", info)
+	}
+}
+
+func Test_WithRotateRule_keepFiles(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	rw, err := NewRotateWriterWithOpt(logPath, WithRotateRule(evenHourRule{}), WithMaxSize(10), WithKeepFiles(2),
+		WithCleanInterval(time.Millisecond), WithCleanBatch(10))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	for i := 0; i < 6; i++ {
+		_, _ = rw.Write([]byte("hello world\n"))
+	}
+	// 后缀包含字母的文件同样由写入器管理
+	var matches []string
+	for i := 0; i < 100; i++ {
+		if matches, _ = filepath.Glob(logPath + ".*"); len(matches) == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	current := rw.(*rotateWriter).current
+	if err = rw.Close(); err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	if len(matches) != 2 || current.Seq != 5 {
+		t.Errorf("unexpected files %v, current %+v", matches, current)
+	}
+	if seq := lastSeq(rw.(*rotateWriter).namer, current.PeriodPath); seq != 5 {
+		t.Errorf("lastSeq() = %d, want 5", seq)
+	}
+}
+
+func Test_AddRotateRule_concurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_ = AddRotateRule("concurrent"+strconv.Itoa(i), time.Hour, func() string { return "" })
+			_, _ = ParseRotateRule("hour")
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < 10; i++ {
+		if _, err := ParseRotateRule("concurrent" + strconv.Itoa(i)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func Test_parseDurationRule(t *testing.T) {
+	cases := map[string]time.Duration{
+		"90s":                   90 * time.Second,
+		"45min":                 45 * time.Minute,
+		"2h":                    2 * time.Hour,
+		"3d":                    3 * day,
+		"0h":                    0,
+		"200000d":               0,
+		"99999999999999999999s": 0,
+	}
+	for rule, want := range cases {
+		got, ok := parseDurationRule(rule)
+		if got != want || ok != (want > 0) {
+			t.Errorf("parseDurationRule(%q) = %v, %v, want %v", rule, got, ok, want)
+		}
+	}
+	if _, err := ParseRotateRule("200000d"); err == nil {
+		t.Error("overflowed duration should be invalid")
+	}
+}
@@ -1,11 +1,14 @@
 package rotw
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sync"
 	"time"
@@ -17,34 +20,260 @@ type Option func(*RotateWriterConfig)
 type RotateWriterConfig struct {
 	// 最多保留多少个文件, Optional, 默认0，即不删除文件
 	KeepFiles int
+	// 文件最长保留时长，按文件名中的周期起始时间计算，超过后删除, Optional, 默认0，即不按时长删除
+	//
+	// 可以与 KeepFiles 同时使用，任一条件满足即删除；文件名中无法解析出时间的文件不按时长删除
+	MaxAge time.Duration
+	// 所有文件的总字节数上限，超过后从最早的文件开始删除, Optional, 默认0，即不按总大小删除
+	//
+	// 统计所有由写入器管理的文件，包括正在写入的文件，正在写入的文件不会被删除；与 KeepFiles、MaxAge 同时使用时最严格的条件生效
+	MaxTotalSize int64
+	// 自定义文件保留策略, Optional, 与 KeepFiles、MaxAge、MaxTotalSize 同时配置时最严格的条件生效
+	//
+	// 可以使用 CountPolicy、AgePolicy、SizePolicy 及 All 组合，也可以实现 RetentionPolicy，eg: 保留每周一的文件
+	RetentionPolicy RetentionPolicy
+	// 清理过期文件时每批之间的间隔, Optional, 默认1s
+	CleanInterval time.Duration
+	// 清理过期文件时每批删除的文件数, Optional, 默认1
+	CleanBatch int
+	// 清理过期文件时每秒最多释放的字节数, Optional, 默认0，即不限制
+	//
+	// 超过该大小的文件先按该大小逐秒截断再删除，避免一次删除大文件时产生 I/O 抖动
+	CleanBytesPerSec int64
+	// 日志目录所在文件系统的最小可用字节数, Optional, 默认0，即不检查
+	//
+	// 每次分割文件时以及每隔 CheckSpan 检查一次，低于阈值时从最早的文件开始删除，不受 KeepFiles 等条件限制，直到可用空间恢复
+	MinFreeBytes uint64
+	// 日志目录所在文件系统的最小可用空间百分比，取值范围 0~100, Optional, 默认0，即不检查，与 MinFreeBytes 任一条件不满足即视为空间不足
+	MinFreePercent float64
+	// 删除所有可以删除的文件后可用空间仍然不足时是否停止写入, Optional, 默认 false
+	//
+	// 停止写入时 Write 直接丢弃数据并返回成功，避免每次写入都因为磁盘已满而失败；可用空间恢复后自动恢复写入
+	StopOnLowDisk bool
+	// 可用空间不足时的回调, Optional, 停止写入后可用空间恢复时也会回调
+	OnLowDisk func(DiskEvent)
+	// 是否在文件写完后使用 gzip 压缩, Optional, 默认 false
+	//
+	// 切换到新的周期或分段后，在后台将写完的文件压缩为 .gz 文件，压缩文件落盘后删除原文件；压缩后的文件同样计入 KeepFiles 等清理条件
+	Compress bool
+	// gzip 压缩级别，取值范围同 compress/gzip, Optional, 默认0，即 gzip.DefaultCompression
+	CompressLevel int
+	// 自定义文件压缩器, Optional, 设置后忽略 Compress 和 CompressLevel，使用该压缩器压缩写完的文件
+	//
+	// 可以使用 NewGzipCompressor、NewZlibCompressor、NewFlateCompressor，也可以实现 Compressor 接入其他压缩算法，eg: zstd；
+	// 带有该压缩器扩展名或内置压缩器扩展名的文件都视为分割后的文件，扩展名不能是 .1 这样的纯数字
+	Compressor Compressor
+	// 归档目录, Optional, 默认为空，即不归档
+	//
+	// 文件写完（开启压缩时为压缩后）移动到该目录，保持文件在原目录树下的相对路径；跨文件系统时复制并落盘后删除原文件。
+	// 配置后 KeepFiles、MaxAge、MaxTotalSize 作用于归档目录中的文件，以及因归档目录中已有同名文件等原因无法归档的文件
+	ArchiveDir string
 	// 默认文件分割规则
 	//
-	// 1min, 5min, 10min, 30min, hour, day
+	// 1min, 5min, 10min, 15min, 30min, hour, day, week, month, year
 	//
 	// 可以使用 AddRotateRule 添加自定义规则, Optional, 默认规则为 no
+	//
+	// 也可以直接使用时长，eg: "90s", "45min", "2h", "3d"，文件名精度由时长的粒度决定
+	//
+	// 也可以使用标准5段式 cron 表达式，在每次触发时分割文件，文件名使用周期起始时间，格式：2006-01-02_1504
+	//
+	// eg: "0 */6 * * *" 每6小时分割一次，"30 2 * * *" 每天02:30分割一次
 	Rule string
+	// 自定义文件分割规则, Optional, 设置后忽略 Rule，只对当前写入器生效
+	RotateRule RotateRule
 	// 文件路径: eg: xxx/xxx.log
+	//
+	// 支持占位符，在创建写入器时展开一次：{hostname} 主机名，{pid} 进程号，{env:NAME} 环境变量 NAME 的值
+	//
+	// eg: log/{hostname}/app-{env:POD_NAME}.log，多个实例共享存储时各自只清理自己的文件
 	LogPath string
+	// 文件名模板, Optional, 设置后分割后的文件名由模板生成，不再在 LogPath 后追加后缀，此时 LogPath 可以为空
+	//
+	// 支持的占位符：%Y 年，%m 月，%d 日，%H 时，%M 分，%S 秒，%% 百分号，均使用周期的起始时间；
+	// {date} 规则生成的文件名后缀（不含开头的 .），{seq} 分段序号（补零到3位）
+	//
+	// 模板中没有 {seq} 时，分段序号以 .001 的形式插入到扩展名之前；规则不分割文件时（eg: no）不能使用时间和 {date} 占位符
+	//
+	// 同样支持 LogPath 中的 {hostname}、{pid}、{env:NAME} 占位符
+	//
+	// eg: log/app-%Y%m%d-%H.log, log/app.{date}.{seq}.log
+	FilenamePattern string
+	// 是否在 LogPath 维护指向当前文件的符号链接, Optional, 默认 false
+	//
+	// 每次切换文件时通过重命名临时链接原子地更新，便于 tail -F 等工具使用固定路径；LogPath 已经是普通文件时不会覆盖
+	CurrentLink bool
 	// 检查文件是否打开的间隔时间, Optional, 默认1s
+	//
+	// 配置 ReopenSignals 后可以设置较长的间隔，由信号触发重新打开文件
 	CheckSpan time.Duration
+	// 单个文件最大字节数，超过后在当前周期内按序号切分新文件, Optional, 默认0，即不按大小分割
+	//
+	// eg: test.log.2024-01-02_15, test.log.2024-01-02_15.001, test.log.2024-01-02_15.002
+	//
+	// 分段序号补零到3位，按文件名排序即分段的先后顺序
+	MaxSize int64
+	// 单个文件最大行数，超过后在当前周期内按序号切分新文件, Optional, 默认0，即不按行数分割
+	//
+	// 可以与 Rule、MaxSize 同时使用，任一条件满足即切分
+	MaxLines int64
+	// 文件分割对齐和文件名使用的时区, Optional, 默认为 time.Local
+	//
+	// eg: time.UTC，不受进程 TZ 环境变量影响
+	Location *time.Location
+	// 收到这些信号时重新打开当前文件, Optional
+	//
+	// 用于配合外部 logrotate 等工具移动文件后立即切换到新文件，不必等待 CheckSpan 轮询
+	ReopenSignals []os.Signal
+	// 收到这些信号时立即切换到下一个分段文件，效果同 Rotate, Optional
+	RotateSignals []os.Signal
+	// 文件超过该时长没有写入时关闭文件，释放文件描述符, Optional, 默认0，即不关闭
+	//
+	// 下次写入时切换到当前周期的下一个分段文件，周期已经变化时写入新周期的文件；关闭的文件没有数据时继续追加
+	IdleTimeout time.Duration
+	// 文件写入策略, Optional, 默认 direct
+	//
+	// direct: 直接写入分割后的文件，eg: test.log.2024-01-02_15
+	//
+	// rename: 始终写入 LogPath，分割时将其重命名为分割后的文件名并重新创建 LogPath，便于其他工具使用固定路径读取
+	WriteStrategy string
+	// 是否只写入由当前写入器新创建的文件, Optional, 默认 false
+	//
+	// 开启后使用 O_EXCL 创建文件，文件已经存在时（例如重启或者多个实例在同一周期内启动）切换到下一个空闲的分段序号，
+	// 不会与其他实例写入同一个文件；同时对当前文件加锁，压缩、归档和清理时跳过被其他写入器锁定的文件
+	Exclusive bool
+	// 是否对当前文件加排他锁, Optional, 默认 false
+	//
+	// 文件已被其他写入器锁定时切换到下一个空闲的分段序号，压缩、归档和清理时跳过被其他写入器锁定的文件；
+	// linux/macOS 使用建议锁 flock，只对同样加锁的写入器生效
+	LockFile bool
 }
 
 func (rw *RotateWriterConfig) check() error {
 	if len(rw.Rule) == 0 {
 		rw.Rule = "no"
 	}
-	if len(rw.LogPath) == 0 {
+	rw.LogPath = expandPath(rw.LogPath)
+	rw.FilenamePattern = expandPath(rw.FilenamePattern)
+	rw.ArchiveDir = expandPath(rw.ArchiveDir)
+	if len(rw.LogPath) == 0 && len(rw.FilenamePattern) == 0 {
 		return errors.New("log path is empty")
 	}
+	if rw.CurrentLink && len(rw.LogPath) == 0 {
+		return errors.New("log path is empty, current link requires log path")
+	}
+	// 不分割文件的规则没有周期起始时间，模板中不能使用时间和 {date} 占位符
+	if len(rw.FilenamePattern) > 0 && len(newTemplateNamer(rw.FilenamePattern).periodVerbs) > 0 {
+		if rule, err := rw.rotateRule(); err == nil && rule.Start(nowFunc()).IsZero() {
+			return errors.New("filename pattern has period placeholders, but the rule has no period")
+		}
+	}
 	if rw.CheckSpan <= 0 {
 		rw.CheckSpan = time.Second * 1
 	}
+	if rw.MaxSize < 0 {
+		return errors.New("max size is negative")
+	}
+	if rw.MaxLines < 0 {
+		return errors.New("max lines is negative")
+	}
+	if rw.Location == nil {
+		rw.Location = time.Local
+	}
+	if rw.MaxAge < 0 {
+		return errors.New("max age is negative")
+	}
+	if rw.MaxTotalSize < 0 {
+		return errors.New("max total size is negative")
+	}
+	if rw.CompressLevel < gzip.HuffmanOnly || rw.CompressLevel > gzip.BestCompression {
+		return errors.New("invalid compress level")
+	}
+	if len(rw.ArchiveDir) > 0 && filepath.Clean(rw.ArchiveDir) == filepath.Clean(archiveBaseDir(rw)) {
+		return errors.New("archive dir is the same as log dir")
+	}
+	if rw.Compressor != nil {
+		if err := checkCompressExt(rw.Compressor.Ext()); err != nil {
+			return err
+		}
+	}
+	if rw.CleanInterval <= 0 {
+		rw.CleanInterval = time.Second
+	}
+	if rw.CleanBatch <= 0 {
+		rw.CleanBatch = 1
+	}
+	if rw.CleanBytesPerSec < 0 {
+		return errors.New("clean bytes per second is negative")
+	}
+	if rw.MinFreePercent < 0 || rw.MinFreePercent > 100 {
+		return errors.New("min free percent out of range")
+	}
+	if rw.IdleTimeout < 0 {
+		return errors.New("idle timeout is negative")
+	}
+	if rw.WriteStrategy == StrategyRename {
+		if len(rw.LogPath) == 0 {
+			return errors.New("log path is empty, rename strategy requires log path")
+		}
+		if rw.CurrentLink {
+			return errors.New("current link conflicts with rename strategy")
+		}
+		if rw.Exclusive || rw.LockFile {
+			return errors.New("exclusive and lock file conflict with rename strategy")
+		}
+	}
 	return nil
 }
 
+// hasRetention 是否配置了清理过期文件的条件
+func (rw *RotateWriterConfig) hasRetention() bool {
+	return rw.KeepFiles > 0 || rw.MaxAge > 0 || rw.MaxTotalSize > 0 || rw.RetentionPolicy != nil
+}
+
+// retentionPolicy 将 KeepFiles、MaxAge、MaxTotalSize 和 RetentionPolicy 组合为一个文件保留策略
+func (rw *RotateWriterConfig) retentionPolicy() RetentionPolicy {
+	policies := []RetentionPolicy{CountPolicy(rw.KeepFiles), AgePolicy(rw.MaxAge), SizePolicy(rw.MaxTotalSize)}
+	if rw.RetentionPolicy != nil {
+		policies = append(policies, rw.RetentionPolicy)
+	}
+	return All(policies...)
+}
+
+// compressor 获取文件压缩器，优先使用 Compressor，否则在开启 Compress 时使用 gzip，不压缩时返回 nil
+func (rw *RotateWriterConfig) compressor() Compressor {
+	if rw.Compressor != nil {
+		return rw.Compressor
+	}
+	if !rw.Compress {
+		return nil
+	}
+	level := rw.CompressLevel
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return NewGzipCompressor(level)
+}
+
+// lockFiles 是否对当前文件加锁，开启 Exclusive 时同样加锁，便于其他写入器识别正在写入的文件
+func (rw *RotateWriterConfig) lockFiles() bool {
+	return rw.Exclusive || rw.LockFile
+}
+
+// rotateRule 获取文件分割规则，优先使用 RotateRule，否则解析 Rule
+func (rw *RotateWriterConfig) rotateRule() (RotateRule, error) {
+	if rw.RotateRule != nil {
+		return rw.RotateRule, nil
+	}
+	return ParseRotateRule(rw.Rule)
+}
+
 // RotateWriter 文件分割写入器
 type RotateWriter interface {
 	io.WriteCloser
+	// Rotate 立即关闭当前文件并在当前周期内切换到下一个分段文件，
+	// 与定时分割一样会触发回调，包括 KeepFiles 的过期文件清理
+	Rotate() error
 }
 
 type rotateWriter struct {
@@ -53,9 +282,39 @@ type rotateWriter struct {
 	fileInfo os.FileInfo
 	// 文件分割信息生成器
 	rig RotateInfoGenerator
+	// 文件命名器，用于识别需要清理的文件
+	namer fileNamer
+	// 文件写入策略
+	strategy writeStrategy
+	// 文件压缩器，不压缩时为 nil
+	compressor Compressor
+	// 归档文件的命名器，用于识别归档目录中需要清理的文件，不归档时为 nil
+	archive fileNamer
+	// 当前文件对应的分割信息
+	current rotateInfo
 	// 当前文件
 	file *os.File
+	// 当前文件大小
+	size int64
+	// 当前文件行数，仅在 MaxLines > 0 时统计
+	lines int64
+	// 最近一次写入或打开文件的时间
+	lastWrite time.Time
+	// 文件是否因为空闲被关闭
+	idle bool
 	mux  sync.Mutex
+	// 待处理的压缩和清理请求，容量为1，处理期间的多次分割合并为一次，由 doMaintain 协程依次处理
+	maintainCh chan struct{}
+	// 待处理的磁盘空间检查请求，容量为1
+	guardCh chan struct{}
+	// 是否因为磁盘空间不足停止写入
+	paused bool
+	// 写入器的上下文，关闭时取消，用于中断后台任务
+	ctx    context.Context
+	cancel context.CancelFunc
+	// 正在执行的后台任务，taskMux 保证关闭后不再登记新的任务
+	tasks   sync.WaitGroup
+	taskMux sync.Mutex
 	// 关闭信号，用于通知检查文件是否打开的协程退出
 	closed chan struct{}
 }
@@ -83,15 +342,34 @@ func NewRotateWriter(cfg *RotateWriterConfig) (RotateWriter, error) {
 	if err := cfg.check(); err != nil {
 		return nil, err
 	}
+	rule, errRule := cfg.rotateRule()
+	if errRule != nil {
+		return nil, errRule
+	}
 	// 创建文件信息生成器
-	rig, errRig := NewRotateInfoGenerator(cfg.Rule, cfg.LogPath)
+	rig, errRig := newRotateInfoGenerator(cfg)
 	if errRig != nil {
 		return nil, errRig
 	}
+	namer := newFileNamer(cfg, rule)
+	strategy, errStrategy := newWriteStrategy(cfg, rule, namer)
+	if errStrategy != nil {
+		rig.Stop()
+		return nil, errStrategy
+	}
 	rw := &rotateWriter{
-		cfg:    cfg,
-		rig:    rig,
-		closed: make(chan struct{}),
+		cfg:        cfg,
+		rig:        rig,
+		namer:      namer,
+		strategy:   strategy,
+		compressor: cfg.compressor(),
+		maintainCh: make(chan struct{}, 1),
+		guardCh:    make(chan struct{}, 1),
+		closed:     make(chan struct{}),
+	}
+	rw.ctx, rw.cancel = context.WithCancel(context.Background())
+	if len(cfg.ArchiveDir) > 0 {
+		rw.archive = newArchiveNamer(cfg, rule)
 	}
 	if err := rw.init(); err != nil {
 		errClose := rw.Close()
@@ -115,18 +393,37 @@ func (r *rotateWriter) init() error {
 			_, _ = fmt.Fprintf(os.Stderr, "check file error, err=%v\n", err)
 		}
 	})
-	// KeepFiles > 0 时，开启清理过期文件协程
-	if cfg.KeepFiles > 0 {
-		rig.AddCallbackWithCtx(func(ctx context.Context, val rotateInfo) {
-			r.clean(ctx)
+	// 配置了压缩或清理条件时，开启压缩和清理过期文件协程
+	if r.compressor != nil || r.archive != nil || cfg.hasRetention() {
+		rig.AddCallback(func(rotateInfo) {
+			notify(r.maintainCh)
+		})
+		// 启动时处理上次运行遗留的文件
+		notify(r.maintainCh)
+		go r.runTask(r.doMaintain)
+	}
+	// 配置了最小可用空间时，分割文件时以及定期检查磁盘可用空间
+	if cfg.hasDiskGuard() {
+		rig.AddCallback(func(rotateInfo) {
+			notify(r.guardCh)
 		})
-		// 启动时清理过期文件
-		go r.clean(context.Background())
+		go r.runTask(func() { r.doGuard(cfg.CheckSpan) })
 	}
 	// CheckSpan > 0 时，开启检查文件是否打开的协程
 	if cfg.CheckSpan > 0 {
 		go r.doCheck(cfg.CheckSpan, rig)
 	}
+	// 配置了信号时，开启监听信号的协程
+	if len(cfg.ReopenSignals) > 0 {
+		r.watchSignal(cfg.ReopenSignals, "reopen", r.reopen)
+	}
+	if len(cfg.RotateSignals) > 0 {
+		r.watchSignal(cfg.RotateSignals, "rotate", r.Rotate)
+	}
+	// IdleTimeout > 0 时，开启关闭空闲文件的协程
+	if cfg.IdleTimeout > 0 {
+		go r.doIdle(cfg.IdleTimeout)
+	}
 	return nil
 }
 
@@ -134,47 +431,339 @@ func (r *rotateWriter) init() error {
 func (r *rotateWriter) Write(p []byte) (n int, err error) {
 	r.mux.Lock()
 	defer r.mux.Unlock()
-	return r.file.Write(p)
+	// 磁盘空间不足停止写入时直接丢弃数据
+	if r.paused && !r.isClosed() {
+		return len(p), nil
+	}
+	// 文件因为空闲被关闭，则切换到新的分段文件；打开失败等原因导致没有文件时，重新打开当前文件
+	if r.file == nil {
+		if r.isClosed() {
+			return 0, os.ErrClosed
+		}
+		if err := r.resumeIdle(); err != nil {
+			return 0, err
+		}
+	}
+	var lines int64
+	if r.cfg.MaxLines > 0 {
+		lines = int64(bytes.Count(p, []byte{'\n'}))
+	}
+	// 写入后超过大小或行数限制，则切换到下一个分段文件
+	if r.exceeded(int64(len(p)), lines) {
+		if err := r.roll(); err != nil {
+			return 0, err
+		}
+	}
+	n, err = r.file.Write(p)
+	r.size += int64(n)
+	r.lines += lines
+	r.lastWrite = time.Now()
+	return n, err
+}
+
+// exceeded 判断写入后是否超过大小或行数限制，空文件不切分，避免单次写入过大时产生空文件
+func (r *rotateWriter) exceeded(size int64, lines int64) bool {
+	if r.cfg.MaxSize > 0 && r.size > 0 && r.size+size > r.cfg.MaxSize {
+		return true
+	}
+	if r.cfg.MaxLines > 0 && r.lines > 0 && r.lines+lines > r.cfg.MaxLines {
+		return true
+	}
+	return false
+}
+
+// roll 切换到当前周期的下一个分段文件，调用方需持有锁
+func (r *rotateWriter) roll() error {
+	return r.switchTo(r.rig.Roll())
+}
+
+// Rotate 立即切换到下一个分段文件
+func (r *rotateWriter) Rotate() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.isClosed() {
+		return os.ErrClosed
+	}
+	return r.roll()
+}
+
+// resumeIdle 重新打开空闲关闭的文件，当前周期的文件已有数据时切换到下一个分段，调用方需持有锁
+func (r *rotateWriter) resumeIdle() error {
+	if r.idle && r.size > 0 && r.rig.Get().RotatePath == r.current.RotatePath {
+		return r.roll()
+	}
+	return r.openCurrent()
+}
+
+// reopen 重新打开当前文件，文件被外部移动或删除时会创建新文件
+func (r *rotateWriter) reopen() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.isClosed() {
+		return os.ErrClosed
+	}
+	// 空闲关闭的文件在下次写入时才会打开
+	if r.file == nil && r.idle {
+		return nil
+	}
+	return r.openCurrent()
+}
+
+// openCurrent 打开生成器当前的文件，调用方需持有锁
+func (r *rotateWriter) openCurrent() error {
+	return r.switchTo(r.rig.Get())
+}
+
+// switchTo 打开 info 对应的文件，切换到新的周期或分段时先由写入策略处理写完的文件，调用方需持有锁
+func (r *rotateWriter) switchTo(info rotateInfo) error {
+	if len(r.current.RotatePath) == 0 {
+		if err := r.strategy.Resume(info); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "resume file error, err=%v\n", err)
+		}
+	} else if r.current.RotatePath != info.RotatePath {
+		if r.file != nil {
+			if errClose := r.file.Close(); errClose != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "close file %s error, err=%v\n", r.file.Name(), errClose)
+			}
+			r.file = nil
+		}
+		if err := r.strategy.Finish(r.current); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "finish file %s error, err=%v\n", r.current.RotatePath, err)
+		}
+	}
+	path := r.strategy.Path(info)
+	// 重新打开自己正在使用的文件时不需要独占创建
+	own := r.isFileExists(path)
+	exclusive := r.cfg.Exclusive && !own
+	// 文件锁属于打开文件时的描述符，需要先关闭旧的描述符释放锁
+	if own && r.cfg.lockFiles() && r.file != nil {
+		if errClose := r.file.Close(); errClose != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "close file %s error, err=%v\n", r.file.Name(), errClose)
+		}
+		r.file = nil
+	}
+	for {
+		// 写完的文件已经处理，打开失败时下次写入直接重试打开
+		r.current = info
+		if err := keepDirs(filepath.Dir(path)); err != nil {
+			return err
+		}
+		err := r.open(path, exclusive)
+		if !errors.Is(err, errFileTaken) {
+			return err
+		}
+		// 文件被其他写入器占用，切换到下一个分段
+		info = r.rig.Roll()
+		path = r.strategy.Path(info)
+		exclusive = r.cfg.Exclusive
+	}
 }
 
 // Close 关闭文件分割写入器
 func (r *rotateWriter) Close() error {
+	r.taskMux.Lock()
 	close(r.closed)
+	r.taskMux.Unlock()
+	r.cancel()
 	r.rig.Stop()
+	// 等待压缩、清理等后台任务退出
+	r.tasks.Wait()
+	r.mux.Lock()
+	defer r.mux.Unlock()
 	if r.file == nil {
 		return nil
 	}
 	return r.file.Close()
 }
 
+// runTask 执行压缩、清理等后台任务，写入器已经关闭时不再执行，Close 会等待正在执行的任务退出
+func (r *rotateWriter) runTask(fn func()) {
+	r.taskMux.Lock()
+	if r.isClosed() {
+		r.taskMux.Unlock()
+		return
+	}
+	r.tasks.Add(1)
+	r.taskMux.Unlock()
+	defer r.tasks.Done()
+	fn()
+}
+
+// isClosed 判断写入器是否已经关闭
+func (r *rotateWriter) isClosed() bool {
+	select {
+	case <-r.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// notify 通知后台协程处理，已有待处理的请求时直接返回，处理期间的多次通知合并为一次
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// doMaintain 收到通知时压缩和清理文件，同一时间只有一个协程处理
+func (r *rotateWriter) doMaintain() {
+	for {
+		select {
+		case <-r.closed:
+			return
+		case <-r.maintainCh:
+			r.maintain(r.ctx, r.rig.Get())
+		}
+	}
+}
+
+// maintain 切换文件后压缩写完的文件，并清理过期文件；压缩完成后再清理，避免同时处理同一个文件
+func (r *rotateWriter) maintain(ctx context.Context, info rotateInfo) {
+	// 写入器已经关闭时不再处理，避免 Close 等待文件操作
+	if ctx.Err() != nil {
+		return
+	}
+	if r.compressor != nil || r.archive != nil {
+		// 先确保写入器已经切换到新文件，避免处理正在写入的文件
+		if err := r.check(info); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "check file error, err=%v\n", err)
+		}
+	}
+	if r.compressor != nil {
+		r.compress(ctx)
+	}
+	if r.archive != nil {
+		r.archiveFiles(ctx)
+	}
+	if r.cfg.hasRetention() && ctx.Err() == nil {
+		r.clean(ctx)
+	}
+}
+
+// finishedFiles 获取所有已经写完的文件，不包括正在写入以及被其他写入器锁定的文件
+func (r *rotateWriter) finishedFiles() []namedFile {
+	matches, err := filepath.Glob(r.namer.Glob())
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "glob files error, err=%v\n", err)
+		return nil
+	}
+	r.mux.Lock()
+	current := r.current.RotatePath
+	r.mux.Unlock()
+	active := r.strategy.Path(r.rig.Get())
+	files := make([]namedFile, 0, len(matches))
+	for _, name := range matches {
+		nf, ok := r.namer.Match(name)
+		// rename 写入策略以及 CurrentLink 的 LogPath 不是写完的文件
+		isLogPath := name == r.cfg.LogPath && (r.cfg.WriteStrategy == StrategyRename || r.cfg.CurrentLink)
+		if !ok || name == active || name == current || isLogPath || r.isBusy(name) {
+			continue
+		}
+		files = append(files, nf)
+	}
+	return files
+}
+
+// isBusy 判断文件是否正在被其他写入器写入，即已被其他写入器加锁，这样的文件不能压缩、归档或删除
+//
+// 未开启 Exclusive 和 LockFile 时写入器不加锁，不做检查
+func (r *rotateWriter) isBusy(path string) bool {
+	if !r.cfg.lockFiles() {
+		return false
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	return errors.Is(lockFile(file), errFileTaken)
+}
+
+// compress 压缩所有写完且未压缩的文件
+func (r *rotateWriter) compress(ctx context.Context) {
+	for _, nf := range r.finishedFiles() {
+		if ctx.Err() != nil {
+			return
+		}
+		if len(nf.Ext) > 0 {
+			continue
+		}
+		// 文件可能已经因为磁盘空间不足被删除
+		if errCompress := compressFile(ctx, nf.Path, r.compressor); errCompress != nil && ctx.Err() == nil && !os.IsNotExist(errCompress) {
+			_, _ = fmt.Fprintf(os.Stderr, "compress file %s error, err=%v\n", nf.Path, errCompress)
+		}
+	}
+}
+
+// archiveFiles 将所有写完的文件移动到归档目录
+func (r *rotateWriter) archiveFiles(ctx context.Context) {
+	for _, nf := range r.finishedFiles() {
+		if ctx.Err() != nil {
+			return
+		}
+		target := archivePath(r.cfg, nf.Path)
+		if errMove := moveFile(ctx, nf.Path, target); errMove != nil && ctx.Err() == nil {
+			_, _ = fmt.Fprintf(os.Stderr, "archive file %s error, err=%v\n", nf.Path, errMove)
+		}
+	}
+}
+
 // clean 清理过期文件
 func (r *rotateWriter) clean(ctx context.Context) {
-	info := r.rig.Get()
-	files, err := getExpireFiles(info.RawPath, r.cfg.KeepFiles)
+	active := r.strategy.Path(r.rig.Get())
+	managed, err := getManagedFiles(r.namer, active)
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "get expire files error, err=%v\n", err)
+		_, _ = fmt.Fprintf(os.Stderr, "get managed files error, err=%v\n", err)
 		return
 	}
+	// 配置了归档目录时清理归档目录中的文件，以及日志目录中无法归档的文件（eg: 归档目录中已有同名文件），
+	// 正在写入的文件不计入保留的文件
+	if r.archive != nil {
+		archived, errArchive := getManagedFiles(r.archive, "")
+		if errArchive != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "get archived files error, err=%v\n", errArchive)
+			return
+		}
+		r.mux.Lock()
+		current := r.current.RotatePath
+		r.mux.Unlock()
+		leftover := make([]ManagedFile, 0, len(managed))
+		for _, f := range managed {
+			if !f.Active && f.Path != current {
+				leftover = append(leftover, f)
+			}
+		}
+		managed = mergeManagedFiles(archived, leftover)
+	}
+	files := expireFiles(managed, r.cfg.retentionPolicy(), active)
 	if len(files) == 0 {
 		return
 	}
-	// 每秒删除一个文件，减少删除文件时的压力
-	tm := time.NewTimer(time.Second)
+	// 每隔 CleanInterval 删除 CleanBatch 个文件，减少删除文件时的压力
+	tm := time.NewTimer(r.cfg.CleanInterval)
 	defer tm.Stop()
 	for i := 0; i < len(files); i++ {
-		select {
-		case <-ctx.Done():
-			return
-		case <-tm.C:
+		if i%r.cfg.CleanBatch == 0 {
+			if i > 0 {
+				tm.Reset(r.cfg.CleanInterval)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-tm.C:
+			}
+		}
+		if r.isBusy(files[i]) {
+			continue
 		}
-		now := nowFunc()
-		name := files[i]
-		if errRemove := os.Remove(name); errRemove != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "remove file %s error, err=%v\n", name, err)
+		// 文件可能已经因为磁盘空间不足被删除
+		if errRemove := removeFile(ctx, files[i], r.cfg.CleanBytesPerSec); errRemove != nil && ctx.Err() == nil && !os.IsNotExist(errRemove) {
+			_, _ = fmt.Fprintf(os.Stderr, "remove file %s error, err=%v\n", files[i], errRemove)
 		}
-		cost := time.Since(now)
-		fmt.Printf("[%v] >>> remove file %s, cost: %v\n", nowFunc().Format(time.StampMicro), name, cost)
-		tm.Reset(time.Second)
 	}
 }
 
@@ -193,47 +782,132 @@ func (r *rotateWriter) doCheck(span time.Duration, rig RotateInfoGenerator) {
 	}
 }
 
-// check 检查文件是否存在，不存在则创建目录和文件, 文件存在则检查文件是否被修改过
-func (r *rotateWriter) check(info rotateInfo) error {
+// watchSignal 注册信号监听，并启动协程处理信号
+func (r *rotateWriter) watchSignal(sigs []os.Signal, name string, fn func() error) {
+	ch := make(chan os.Signal, 1)
+	// 在启动协程前注册，保证创建完成后收到的信号不会丢失
+	signal.Notify(ch, sigs...)
+	go r.doSignal(ch, name, fn)
+}
+
+// minIdleCheckSpan 检查文件是否空闲的最小间隔，避免 IdleTimeout 过小时频繁检查
+const minIdleCheckSpan = 10 * time.Millisecond
+
+// doIdle 定期检查文件是否空闲，空闲超时则关闭文件
+func (r *rotateWriter) doIdle(timeout time.Duration) {
+	ticker := time.NewTicker(max(timeout/2, minIdleCheckSpan))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.closed:
+			return
+		case <-ticker.C:
+			r.closeIdle(timeout)
+		}
+	}
+}
+
+// closeIdle 文件超过 timeout 没有写入时关闭文件
+func (r *rotateWriter) closeIdle(timeout time.Duration) {
 	r.mux.Lock()
-	fileExists := r.isFileExists(info.RotatePath)
-	r.mux.Unlock()
+	defer r.mux.Unlock()
+	if r.file == nil || r.isClosed() || time.Since(r.lastWrite) < timeout {
+		return
+	}
+	if err := r.file.Close(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "close idle file %s error, err=%v\n", r.file.Name(), err)
+	}
+	r.file = nil
+	r.idle = true
+}
 
-	if !fileExists {
-		// 文件不存在，则创建目录
-		dir := filepath.Dir(info.RotatePath)
-		if err := keepDirs(dir); err != nil {
-			return err
+// doSignal 收到信号时执行 fn，写入器关闭时退出并取消信号监听
+func (r *rotateWriter) doSignal(ch chan os.Signal, name string, fn func() error) {
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-r.closed:
+			return
+		case sig := <-ch:
+			if err := fn(); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%s on signal %v error, err=%v\n", name, sig, err)
+			}
 		}
 	}
+}
 
+// check 检查文件是否存在，不存在则创建目录和文件, 文件存在则检查文件是否被修改过
+func (r *rotateWriter) check(info rotateInfo) error {
 	r.mux.Lock()
 	defer r.mux.Unlock()
+	// 已经关闭的写入器不再打开文件，避免异步回调在关闭后重新创建文件；空闲关闭的文件在下次写入时才会打开
+	if r.isClosed() || r.file == nil && r.idle {
+		return nil
+	}
 	// 文件存在且没有修改过，则直接返回
-	if r.file != nil && fileExists {
+	if r.file != nil && info.RotatePath == r.current.RotatePath && r.isFileExists(r.strategy.Path(info)) {
 		return nil
 	}
-	// 上一个文件描述符存在，则关闭
-	if r.file != nil {
-		errClose := r.file.Close()
-		if errClose != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "close file %s error, err=%v\n", r.file.Name(), errClose)
-		}
+	// 生成器已经切换到了新的文件，由新文件的检查负责打开
+	if r.file != nil && info.RotatePath != r.rig.Get().RotatePath {
+		return nil
 	}
+	return r.switchTo(info)
+}
+
+// errFileTaken 文件已被其他写入器占用
+var errFileTaken = errors.New("file is taken by another writer")
+
+// open 打开新文件并关闭当前文件，文件已被其他写入器占用时返回 errFileTaken，调用方需持有锁
+func (r *rotateWriter) open(path string, exclusive bool) error {
 	// 创建新文件/打开文件
-	file, err := os.OpenFile(info.RotatePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	flag := os.O_CREATE | os.O_APPEND | os.O_WRONLY
+	if exclusive {
+		flag |= os.O_EXCL
+	}
+	file, err := os.OpenFile(path, flag, 0644)
 	if err != nil {
+		if exclusive && os.IsExist(err) {
+			return errFileTaken
+		}
 		return err
 	}
+	if r.cfg.lockFiles() {
+		if errLock := lockFile(file); errLock != nil {
+			_ = file.Close()
+			return errLock
+		}
+	}
 	// 获取文件信息
 	fileStat, err := file.Stat()
 	if err != nil {
+		_ = file.Close()
 		return err
 	}
+	// 上一个文件描述符存在，则关闭
+	if r.file != nil {
+		errClose := r.file.Close()
+		if errClose != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "close file %s error, err=%v\n", r.file.Name(), errClose)
+		}
+	}
 	// 更新文件信息
 	r.fileInfo = fileStat
+	r.size = fileStat.Size()
+	r.lines = 0
+	if r.cfg.MaxLines > 0 && r.size > 0 {
+		r.lines = countLines(path)
+	}
 	// 更新文件描述符
 	r.file = file
+	r.lastWrite = time.Now()
+	r.idle = false
+	// 更新指向当前文件的链接，文件本身就是 LogPath 时不需要链接
+	if r.cfg.CurrentLink && path != r.cfg.LogPath {
+		if errLink := updateLink(r.cfg.LogPath, path); errLink != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "update link %s error, err=%v\n", r.cfg.LogPath, errLink)
+		}
+	}
 	return nil
 }
 
@@ -256,14 +930,170 @@ func WithKeepFiles(num int) Option {
 	}
 }
 
+func WithMaxAge(age time.Duration) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.MaxAge = age
+	}
+}
+
+func WithMaxTotalSize(size int64) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.MaxTotalSize = size
+	}
+}
+
+func WithCompress(enable bool) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.Compress = enable
+	}
+}
+
+func WithCompressLevel(level int) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.CompressLevel = level
+	}
+}
+
+func WithCompressor(c Compressor) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.Compressor = c
+	}
+}
+
+func WithArchiveDir(dir string) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.ArchiveDir = dir
+	}
+}
+
+func WithRetentionPolicy(policy RetentionPolicy) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.RetentionPolicy = policy
+	}
+}
+
+func WithCleanInterval(interval time.Duration) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.CleanInterval = interval
+	}
+}
+
+func WithCleanBatch(batch int) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.CleanBatch = batch
+	}
+}
+
+func WithCleanBytesPerSec(size int64) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.CleanBytesPerSec = size
+	}
+}
+
+func WithMinFreeBytes(size uint64) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.MinFreeBytes = size
+	}
+}
+
+func WithMinFreePercent(percent float64) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.MinFreePercent = percent
+	}
+}
+
+func WithStopOnLowDisk(enable bool) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.StopOnLowDisk = enable
+	}
+}
+
+func WithOnLowDisk(fn func(DiskEvent)) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.OnLowDisk = fn
+	}
+}
+
 func WithRule(rule string) Option {
 	return func(rw *RotateWriterConfig) {
 		rw.Rule = rule
 	}
 }
 
+func WithFilenamePattern(pattern string) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.FilenamePattern = pattern
+	}
+}
+
+func WithCurrentLink(enable bool) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.CurrentLink = enable
+	}
+}
+
+func WithRotateRule(rule RotateRule) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.RotateRule = rule
+	}
+}
+
 func WithCheckSpan(span time.Duration) Option {
 	return func(rw *RotateWriterConfig) {
 		rw.CheckSpan = span
 	}
 }
+
+func WithMaxSize(size int64) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.MaxSize = size
+	}
+}
+
+func WithMaxLines(lines int64) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.MaxLines = lines
+	}
+}
+
+func WithLocation(loc *time.Location) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.Location = loc
+	}
+}
+
+func WithSignalReopen(sigs ...os.Signal) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.ReopenSignals = sigs
+	}
+}
+
+func WithSignalRotate(sigs ...os.Signal) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.RotateSignals = sigs
+	}
+}
+
+func WithWriteStrategy(strategy string) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.WriteStrategy = strategy
+	}
+}
+
+func WithExclusive(enable bool) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.Exclusive = enable
+	}
+}
+
+func WithLockFile(enable bool) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.LockFile = enable
+	}
+}
+
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(rw *RotateWriterConfig) {
+		rw.IdleTimeout = timeout
+	}
+}
@@ -0,0 +1,45 @@
+//go:build !windows
+
+package rotw
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_signalReopen(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	rw, err := NewRotateWriterWithOpt(logPath, WithCheckSpan(time.Hour), WithSignalReopen(syscall.SIGHUP))
+	if err != nil {
+		t.Fatalf(">> %v\n", err)
+	}
+	defer func() {
+		_ = rw.Close()
+	}()
+	_, _ = rw.Write([]byte("before\n"))
+	// 模拟 logrotate 移动文件
+	if err = os.Rename(logPath, logPath+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, errStat := os.Stat(logPath); errStat == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("file not reopened after signal")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, _ = rw.Write([]byte("after\n"))
+	data, _ := os.ReadFile(logPath)
+	if string(data) != "after\n" {
+		t.Errorf("unexpected content: %q", data)
+	}
+}
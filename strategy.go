@@ -0,0 +1,111 @@
+package rotw
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+const (
+	// StrategyDirect 直接写入分割后的文件，eg: test.log.2024-01-02_15
+	StrategyDirect = "direct"
+	// StrategyRename 始终写入 LogPath，分割时将其重命名为分割后的文件名，再重新创建 LogPath
+	StrategyRename = "rename"
+)
+
+// writeStrategy 文件写入策略，决定数据写入的文件以及文件写完后的处理
+type writeStrategy interface {
+	// Path 获取 info 对应的数据写入的文件路径
+	Path(info rotateInfo) string
+	// Finish 处理写完的文件，调用时文件已经关闭
+	Finish(info rotateInfo) error
+	// Resume 首次打开文件前处理上次运行遗留的文件
+	Resume(info rotateInfo) error
+}
+
+// newWriteStrategy 根据写入器配置创建文件写入策略
+func newWriteStrategy(cfg *RotateWriterConfig, rule RotateRule, namer fileNamer) (writeStrategy, error) {
+	switch cfg.WriteStrategy {
+	case "", StrategyDirect:
+		return directStrategy{}, nil
+	case StrategyRename:
+		return &renameStrategy{
+			cfg:   cfg,
+			path:  cfg.LogPath,
+			rule:  rule,
+			namer: namer,
+			loc:   cfg.Location,
+		}, nil
+	default:
+		return nil, errors.New("invalid write strategy")
+	}
+}
+
+// directStrategy 默认的写入策略，直接写入分割后的文件
+type directStrategy struct{}
+
+func (directStrategy) Path(info rotateInfo) string { return info.RotatePath }
+
+func (directStrategy) Finish(rotateInfo) error { return nil }
+
+func (directStrategy) Resume(rotateInfo) error { return nil }
+
+// renameStrategy 始终写入 LogPath，分割时重命名为分割后的文件名
+type renameStrategy struct {
+	cfg   *RotateWriterConfig
+	path  string
+	rule  RotateRule
+	namer fileNamer
+	loc   *time.Location
+}
+
+func (s *renameStrategy) Path(rotateInfo) string { return s.path }
+
+func (s *renameStrategy) Finish(info rotateInfo) error {
+	// 目标文件已经存在时使用下一个空闲的分段序号，避免覆盖已有文件
+	target := info.RotatePath
+	for seq := info.Seq + 1; s.isTaken(target); seq++ {
+		target = s.namer.Name(info.Start, info.Suffix, seq)
+	}
+	if err := os.Rename(s.path, target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// isTaken 判断分割后的文件名是否已被占用，压缩以及归档后的同名文件同样占用该文件名
+func (s *renameStrategy) isTaken(path string) bool {
+	if path == s.path {
+		return true
+	}
+	paths := []string{path}
+	if len(s.cfg.ArchiveDir) > 0 {
+		paths = append(paths, archivePath(s.cfg, path))
+	}
+	var ext string
+	if c := s.cfg.compressor(); c != nil {
+		ext = c.Ext()
+	}
+	for _, p := range paths {
+		if isPathExists(p) || len(ext) > 0 && isPathExists(p+ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resume LogPath 最后修改时间早于当前周期时，说明是之前周期遗留的文件，按照修改时间所在的周期重命名
+func (s *renameStrategy) Resume(info rotateInfo) error {
+	stat, err := os.Stat(s.path)
+	if err != nil || info.Start.IsZero() || !stat.ModTime().Before(info.Start) {
+		return nil
+	}
+	start, suffix := periodSuffix(s.rule, stat.ModTime().In(s.loc))
+	return s.Finish(rotateInfo{
+		RawPath:    info.RawPath,
+		PeriodPath: s.namer.Name(start, suffix, 0),
+		RotatePath: s.namer.Name(start, suffix, 0),
+		Start:      start,
+		Suffix:     suffix,
+	})
+}
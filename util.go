@@ -1,17 +1,24 @@
 package rotw
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 var nowFunc = time.Now
-var suffixRegexp = regexp.MustCompile(`\.[\d_-]+`)
+var suffixRegexp = regexp.MustCompile(`^\.[\d_-]+(\.\d+)?$`)
+var seqRegexp = regexp.MustCompile(`^\.\d+$`)
+var placeholderRegexp = regexp.MustCompile(`\{(hostname|pid|env:[^{}]+)\}`)
 
 // setNowFunc 设置当前时间函数，用于测试
 func setNowFunc(f func() time.Time) {
@@ -44,19 +51,170 @@ func keepDirs(dir string) error {
 	return nil
 }
 
-// getExpireFiles 获取过期文件列表
-func getExpireFiles(path string, keep int) ([]string, error) {
-	pattern := path + ".*"
-	matches, errGlob := filepath.Glob(pattern)
+// expandPath 展开路径中的占位符：{hostname} 主机名，{pid} 进程号，{env:NAME} 环境变量 NAME 的值
+//
+// 展开后的值中的路径分隔符和 glob 元字符替换为 _，保证只影响文件名的一部分，并且清理过期文件时不会匹配到其他实例的文件
+func expandPath(path string) string {
+	return placeholderRegexp.ReplaceAllStringFunc(path, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		var val string
+		switch {
+		case name == "hostname":
+			val, _ = os.Hostname()
+		case name == "pid":
+			val = strconv.Itoa(os.Getpid())
+		default:
+			val = os.Getenv(strings.TrimPrefix(name, "env:"))
+		}
+		return pathValueReplacer.Replace(val)
+	})
+}
+
+var pathValueReplacer = strings.NewReplacer("/", "_", "\\", "_", "*", "_", "?", "_", "[", "_", "]", "_")
+
+// transformFile 读取 path 经过 fn 处理后写入 target，保留原文件的权限和修改时间
+//
+// 先写入临时文件并落盘，再重命名为 target，中途失败或者 ctx 被取消时删除临时文件；
+// target 已经存在时返回 os.ErrExist，不覆盖已有文件
+func transformFile(ctx context.Context, path string, target string, fn func(dst io.Writer, src io.Reader) error) error {
+	if isPathExists(target) {
+		return &os.PathError{Op: "transform", Path: target, Err: os.ErrExist}
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+	stat, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	tmp := target + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, stat.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	err = fn(dst, &ctxReader{ctx: ctx, r: src})
+	if err == nil {
+		err = dst.Sync()
+	}
+	if errClose := dst.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = renameNoReplace(tmp, target)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	// 保留原文件的修改时间，无法从文件名解析时间的文件按修改时间排序
+	_ = os.Chtimes(target, stat.ModTime(), stat.ModTime())
+	return nil
+}
+
+// renameNoReplace 将 path 重命名为 target，target 已经存在时返回 os.ErrExist
+//
+// 优先使用硬链接保证不覆盖 target，文件系统不支持硬链接时检查 target 后再重命名
+func renameNoReplace(path string, target string) error {
+	errLink := os.Link(path, target)
+	if errLink == nil {
+		return os.Remove(path)
+	}
+	if os.IsExist(errLink) || isPathExists(target) {
+		return &os.PathError{Op: "rename", Path: target, Err: os.ErrExist}
+	}
+	return os.Rename(path, target)
+}
+
+// ctxReader 在 ctx 被取消后停止读取，用于中断正在进行的文件处理
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// removeFile 删除文件，budget > 0 时超过 budget 字节的文件先每秒截断 budget 字节，剩余部分不超过 budget 时再删除
+func removeFile(ctx context.Context, path string, budget int64) error {
+	if budget <= 0 {
+		return os.Remove(path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tm := time.NewTimer(time.Second)
+	defer tm.Stop()
+	for size := info.Size(); size > budget; {
+		size -= budget
+		if err = os.Truncate(path, size); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tm.C:
+			tm.Reset(time.Second)
+		}
+	}
+	return os.Remove(path)
+}
+
+// isPathExists 判断路径是否存在
+func isPathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// updateLink 原子地将 link 更新为指向 target 的符号链接，尽量使用相对路径
+//
+// 先创建临时链接再重命名覆盖 link，保证任意时刻 link 都存在；link 是普通文件时返回错误，避免覆盖数据
+func updateLink(link string, target string) error {
+	info, err := os.Lstat(link)
+	if err == nil && info.Mode()&os.ModeSymlink == 0 {
+		return errors.New("file exists and is not a symlink")
+	}
+	// 链接的目标是相对于链接所在目录解析的
+	if rel, errRel := filepath.Rel(filepath.Dir(link), target); errRel == nil {
+		target = rel
+	} else if abs, errAbs := filepath.Abs(target); errAbs == nil {
+		target = abs
+	}
+	tmp := link + ".link-tmp"
+	_ = os.Remove(tmp)
+	if err = os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, link); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// managedFile 由写入器管理的文件，以及从文件名中识别出的周期和分段序号，用于排序
+type managedFile struct {
+	ManagedFile
+	nf namedFile
+}
+
+// getManagedFiles 获取由写入器管理的文件，按从旧到新的顺序排列
+//
+// active 为正在写入的文件，不在匹配结果中时（eg: rename 写入策略）作为最新的文件加入
+func getManagedFiles(namer fileNamer, active string) ([]ManagedFile, error) {
+	matches, errGlob := filepath.Glob(namer.Glob())
 	if errGlob != nil {
 		return nil, errGlob
 	}
-	// 文件少于等于keep个，没有过期文件
-	if len(matches) <= keep {
-		return nil, nil
-	}
-	fileInfos := make([]os.FileInfo, 0, len(matches))
-	prefix := filepath.Base(path)
+	files := make([]managedFile, 0, len(matches))
 	for i := 0; i < len(matches); i++ {
 		name := matches[i]
 		info, err := os.Stat(name)
@@ -72,26 +230,77 @@ func getExpireFiles(path string, keep int) ([]string, error) {
 			continue
 		}
 		// 文件名规则不正确，跳过
-		if !isFilenameMatch(prefix, info.Name()) {
+		nf, ok := namer.Match(name)
+		if !ok {
 			continue
 		}
-		fileInfos = append(fileInfos, info)
+		files = append(files, managedFile{
+			ManagedFile: ManagedFile{
+				Path:    nf.Path,
+				Time:    nf.Time,
+				ModTime: info.ModTime(),
+				Size:    info.Size(),
+				Seq:     nf.Seq,
+				Active:  nf.Path == active,
+			},
+			nf: nf,
+		})
 	}
-	// 按文件创建时间排序
-	sort.Slice(fileInfos, func(i int, j int) bool {
-		return createTime(fileInfos[i]) < createTime(fileInfos[j])
+	// 按文件名中的周期起始时间排序，时间相同时按周期和分段序号排序；
+	// 不使用 ctime，避免 chmod、备份工具等修改 inode 后改变文件顺序
+	sort.Slice(files, func(i int, j int) bool {
+		ti, tj := files[i].sortTime(), files[j].sortTime()
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return lessNamedFile(files[i].nf, files[j].nf)
 	})
+	ret := make([]ManagedFile, 0, len(files)+1)
+	hasActive := false
+	for _, f := range files {
+		ret = append(ret, f.ManagedFile)
+		hasActive = hasActive || f.Active
+	}
+	if len(active) > 0 && !hasActive {
+		if info, err := os.Stat(active); err == nil {
+			ret = append(ret, ManagedFile{Path: active, ModTime: info.ModTime(), Size: info.Size(), Active: true})
+		}
+	}
+	return ret, nil
+}
 
+// getExpireFiles 获取过期文件列表，正在写入的文件不会过期
+func getExpireFiles(namer fileNamer, policy RetentionPolicy, active string) ([]string, error) {
+	files, err := getManagedFiles(namer, active)
+	if err != nil {
+		return nil, err
+	}
+	return expireFiles(files, policy, active), nil
+}
+
+// expireFiles 从按从旧到新排列的文件中选出过期文件，正在写入的文件不会过期
+func expireFiles(files []ManagedFile, policy RetentionPolicy, active string) []string {
 	ret := make([]string, 0)
-	dir := filepath.Dir(path)
-	for i := 0; i < len(fileInfos)-keep; i++ {
-		name := filepath.Join(dir, fileInfos[i].Name())
-		ret = append(ret, name)
+	for _, f := range policy.Expire(files, nowFunc()) {
+		if f.Active || f.Path == active {
+			continue
+		}
+		ret = append(ret, f.Path)
 	}
-	return ret, nil
+	return ret
 }
 
-// isFilenameMatch 检查文件名是否满足前缀，且后缀格式为 \.[\d_-]+ 的正则表达式
+// mergeManagedFiles 合并多组文件，按从旧到新的顺序排列
+func mergeManagedFiles(a []ManagedFile, b []ManagedFile) []ManagedFile {
+	ret := make([]ManagedFile, 0, len(a)+len(b))
+	ret = append(append(ret, a...), b...)
+	sort.SliceStable(ret, func(i int, j int) bool {
+		return ret[i].sortTime().Before(ret[j].sortTime())
+	})
+	return ret
+}
+
+// isFilenameMatch 检查文件名是否满足前缀，且后缀格式为 \.[\d_-]+ 的正则表达式，允许带有 \.\d+ 的分段序号
 func isFilenameMatch(prefix string, name string) bool {
 	if !strings.HasPrefix(name, prefix) {
 		return false
@@ -100,5 +309,54 @@ func isFilenameMatch(prefix string, name string) bool {
 	if len(suffix) == 0 || suffix[0] != '.' {
 		return false
 	}
-	return suffixRegexp.MatchString(name)
+	return suffixRegexp.MatchString(suffix)
+}
+
+// segmentSeqWidth 分段序号的宽度，不足时补零，按字符串排序时与序号的数值顺序一致，eg: .001, .002, .010
+const segmentSeqWidth = 3
+
+// formatSeq 格式化分段序号，不足 segmentSeqWidth 位时补零
+func formatSeq(seq int) string {
+	return fmt.Sprintf("%0*d", segmentSeqWidth, seq)
+}
+
+// segmentPath 获取周期文件的分段路径，序号为0时即周期文件本身
+func segmentPath(path string, seq int) string {
+	if seq <= 0 {
+		return path
+	}
+	return path + "." + formatSeq(seq)
+}
+
+// countLines 统计文件已有的行数，用于重新打开文件时恢复行数
+func countLines(path string) int64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	var lines int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, errRead := file.Read(buf)
+		lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+		if errRead != nil {
+			return lines
+		}
+	}
+}
+
+// splitSeq 拆分文件名中的周期部分和分段序号
+func splitSeq(name string) (string, int) {
+	idx := strings.LastIndexByte(name, '.')
+	if idx < 0 || !seqRegexp.MatchString(name[idx:]) {
+		return name, 0
+	}
+	seq, err := strconv.Atoi(name[idx+1:])
+	if err != nil {
+		return name, 0
+	}
+	return name[:idx], seq
 }
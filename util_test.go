@@ -1,7 +1,13 @@
 package rotw
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -18,3 +24,175 @@ func Test_SetNowFunc(t *testing.T) {
 		t.Error("nowFunc should be changed")
 	}
 }
+
+func Test_isFilenameMatch(t *testing.T) {
+	cases := map[string]bool{
+		"test.log.2024-01-02_15":   true,
+		"test.log.2024-01-02_15.1": true,
+		"test.log.1":               true,
+		"test.log":                 false,
+		"test.log.bak":             false,
+		"test.log.2024-01-02.bak":  false,
+		"test.logx.2024-01-02":     false,
+	}
+	for name, want := range cases {
+		if got := isFilenameMatch("test.log", name); got != want {
+			t.Errorf("isFilenameMatch(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func Test_segmentPath(t *testing.T) {
+	names := make([]string, 0, 12)
+	for seq := 0; seq < 12; seq++ {
+		names = append(names, segmentPath("test.log.2024-01-02", seq))
+	}
+	// 补零后按字符串排序即分段的先后顺序
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("segment paths should be sorted, got %v", names)
+	}
+	for seq, name := range names {
+		if _, got := splitSeq(strings.TrimPrefix(name, "test.log")); got != seq {
+			t.Errorf("splitSeq(%s) = %d, want %d", name, got, seq)
+		}
+	}
+	if names[10] != "test.log.2024-01-02.010" {
+		t.Errorf("unexpected segment path %s", names[10])
+	}
+}
+
+func Test_expandPath(t *testing.T) {
+	t.Setenv("ROTW_POD", "pod/1*")
+	hostname, _ := os.Hostname()
+	hostname = pathValueReplacer.Replace(hostname)
+	pid := strconv.Itoa(os.Getpid())
+	cases := map[string]string{
+		"log/test.log":                    "log/test.log",
+		"log/{hostname}/test.log":         "log/" + hostname + "/test.log",
+		"log/test-{pid}.log":              "log/test-" + pid + ".log",
+		"log/test-{env:ROTW_POD}.log":     "log/test-pod_1_.log",
+		"log/test-{env:ROTW_MISSING}.log": "log/test-.log",
+		"log/app.{date}.{seq}-{pid}.log":  "log/app.{date}.{seq}-" + pid + ".log",
+	}
+	for path, want := range cases {
+		if got := expandPath(path); got != want {
+			t.Errorf("expandPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func Test_getExpireFiles_maxAge(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.Local)
+	setNowFunc(func() time.Time { return now })
+	defer setNowFunc(time.Now)
+
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	cfg := &RotateWriterConfig{Rule: "day", LogPath: logPath, Location: time.Local}
+	rule, _ := cfg.rotateRule()
+	namer := newFileNamer(cfg, rule)
+	for _, name := range []string{".2024-01-01", ".2024-01-07", ".2024-01-07.1", ".2024-01-09", ".2024-01-10", ".1"} {
+		if err := os.WriteFile(logPath+name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := getExpireFiles(namer, AgePolicy(72*time.Hour), logPath+".2024-01-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	want := []string{logPath + ".2024-01-01", logPath + ".2024-01-07", logPath + ".2024-01-07.1"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("getExpireFiles() = %v, want %v", files, want)
+	}
+}
+
+func Test_getExpireFiles_maxTotalSize(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	cfg := &RotateWriterConfig{Rule: "day", LogPath: logPath, Location: time.Local}
+	rule, _ := cfg.rotateRule()
+	namer := newFileNamer(cfg, rule)
+	for _, name := range []string{".2024-01-07", ".2024-01-08", ".2024-01-09", ".2024-01-10"} {
+		if err := os.WriteFile(logPath+name, []byte("0123456789"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	active := logPath + ".2024-01-10"
+	cases := []struct {
+		policy RetentionPolicy
+		want   []string
+	}{
+		{SizePolicy(25), []string{".2024-01-07", ".2024-01-08"}},
+		{SizePolicy(40), []string{}},
+		// 最严格的条件生效
+		{All(CountPolicy(3), SizePolicy(25)), []string{".2024-01-07", ".2024-01-08"}},
+		{All(CountPolicy(1), SizePolicy(25)), []string{".2024-01-07", ".2024-01-08", ".2024-01-09"}},
+	}
+	for i, c := range cases {
+		files, err := getExpireFiles(namer, c.policy, active)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(files)
+		want := make([]string, 0, len(c.want))
+		for _, suffix := range c.want {
+			want = append(want, logPath+suffix)
+		}
+		if !reflect.DeepEqual(files, want) {
+			t.Errorf("case %d: getExpireFiles() = %v, want %v", i, files, want)
+		}
+	}
+}
+
+func Test_getExpireFiles_order(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	cfg := &RotateWriterConfig{Rule: "day", LogPath: logPath, Location: time.Local}
+	rule, _ := cfg.rotateRule()
+	namer := newFileNamer(cfg, rule)
+	now := time.Now()
+	// 修改时间与文件名中的时间顺序相反，无法解析时间的文件使用修改时间
+	files := map[string]time.Time{
+		".2024-01-07":   now,
+		".2024-01-08":   now.Add(-time.Hour),
+		".2024-01-08.1": now.Add(-2 * time.Hour),
+		".2024-01-09":   now.Add(-3 * time.Hour),
+		".1":            time.Date(2024, 1, 8, 12, 0, 0, 0, time.Local),
+	}
+	for name, mtime := range files {
+		if err := os.WriteFile(logPath+name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(logPath+name, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := getExpireFiles(namer, CountPolicy(1), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{logPath + ".2024-01-07", logPath + ".2024-01-08", logPath + ".2024-01-08.1", logPath + ".1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getExpireFiles() = %v, want %v", got, want)
+	}
+}
+
+func Test_removeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log.2024-01-02")
+	if err := os.WriteFile(path, make([]byte, 25), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 取消后停止截断，保留剩余部分
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := removeFile(ctx, path, 10); err == nil {
+		t.Error("remove should fail after cancel")
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 15 {
+		t.Errorf("file should be truncated to 15 bytes, got %v, %v", info, err)
+	}
+	if err := removeFile(context.Background(), path, 20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file should be removed, err=%v", err)
+	}
+}